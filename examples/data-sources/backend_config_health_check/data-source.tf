@@ -0,0 +1,25 @@
+data "backend_config_health_check" "web" {
+  kind      = "Deployment"
+  name      = "web"
+  namespace = "default"
+}
+
+resource "backend_config" "web" {
+  metadata {
+    name      = "web"
+    namespace = "default"
+  }
+
+  spec {
+    # Keep the health check of the load balancer in line with the readiness probe.
+    health_check {
+      type                = data.backend_config_health_check.web.type
+      port                = data.backend_config_health_check.web.port
+      request_path        = data.backend_config_health_check.web.request_path
+      check_interval_sec  = data.backend_config_health_check.web.check_interval_sec
+      timeout_sec         = data.backend_config_health_check.web.timeout_sec
+      healthy_threshold   = data.backend_config_health_check.web.healthy_threshold
+      unhealthy_threshold = data.backend_config_health_check.web.unhealthy_threshold
+    }
+  }
+}
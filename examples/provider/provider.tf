@@ -1,3 +1,25 @@
 provider "febeconfig" {
-  # example configuration here
-}
\ No newline at end of file
+  config_path = "~/.kube/config"
+
+  # Objects without metadata.namespace are created in this namespace, and plans
+  # targeting a namespace outside of allowed_namespaces fail.
+  namespace          = "team-a"
+  allowed_namespaces = ["team-a", "team-a-*"]
+
+  # Added to every object managed by the provider, resource metadata takes precedence.
+  default_labels = {
+    team          = "platform"
+    "cost-center" = "1234"
+  }
+  default_annotations = {
+    "example.com/managed-by" = "terraform"
+  }
+
+  # Keys written by controllers are never read into state nor removed.
+  ignore_annotations = ["^cloud\\.google\\.com/"]
+  ignore_labels      = ["^argocd\\.argoproj\\.io/"]
+
+  # While decommissioning a cluster, drop its objects from state instead of failing.
+  # unreachable_cluster_behavior = "remove"
+  # missing_crd_behavior         = "remove"
+}
@@ -0,0 +1,5 @@
+# Import a backend config by namespace and name
+terraform import backend_config.example default/example
+
+# Or read it through a specific version of the BackendConfig CRD, v1 or v1beta1
+terraform import backend_config.example v1beta1/default/example
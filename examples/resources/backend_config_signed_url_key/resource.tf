@@ -0,0 +1,32 @@
+resource "backend_config_signed_url_key" "example" {
+  metadata {
+    name      = "example-cdn-key"
+    namespace = "default"
+  }
+
+  # Bump the suffix to rotate the key, the previous one is kept for a day.
+  key_name         = "example-key-1"
+  grace_period_sec = 86400
+}
+
+resource "backend_config" "example" {
+  metadata {
+    name      = "example"
+    namespace = "default"
+  }
+
+  spec {
+    cdn {
+      enabled                      = true
+      signed_url_cache_max_age_sec = 3600
+
+      dynamic "signed_url_keys" {
+        for_each = backend_config_signed_url_key.example.signed_url_keys
+        content {
+          key_name    = signed_url_keys.value.key_name
+          secret_name = signed_url_keys.value.secret_name
+        }
+      }
+    }
+  }
+}
@@ -0,0 +1,2 @@
+# Import the app-protocols annotation of a Service by namespace and Service name
+terraform import service_app_protocols.example default/grpc-backend
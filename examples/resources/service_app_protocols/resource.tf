@@ -0,0 +1,25 @@
+resource "service_app_protocols" "example" {
+  metadata {
+    name      = "grpc-backend"
+    namespace = "default"
+  }
+
+  app_protocols = {
+    "grpc" = "HTTP2"
+    "http" = "HTTP"
+  }
+
+  # Fail the plan when a port's protocol does not match its backend config's health check.
+  health_check_validation = "error"
+}
+
+# The backend config of the gRPC port checks its health over HTTP2 as well.
+resource "service_backend_config_port" "grpc" {
+  metadata {
+    name      = "grpc-backend"
+    namespace = "default"
+  }
+
+  port           = "grpc"
+  backend_config = "grpc"
+}
@@ -0,0 +1,2 @@
+# Import the backend-config annotation of a Service by namespace and Service name
+terraform import service_backend_config.example default/web
@@ -0,0 +1,14 @@
+resource "service_backend_config" "example" {
+  metadata {
+    name      = "web"
+    namespace = "default"
+  }
+
+  # Used by every port without a port-specific backend config.
+  default = backend_config.example.metadata[0].name
+
+  ports = {
+    "https" = "web-tls"
+    "8443"  = "web-tls"
+  }
+}
@@ -0,0 +1,2 @@
+# Import the entry of one port by namespace, Service name and port
+terraform import service_backend_config_port.api default/gateway/api
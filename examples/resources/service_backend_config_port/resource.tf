@@ -0,0 +1,20 @@
+# Each team attaches the backend config of its own port of the shared Service.
+resource "service_backend_config_port" "api" {
+  metadata {
+    name      = "gateway"
+    namespace = "default"
+  }
+
+  port           = "api"
+  backend_config = "api"
+}
+
+resource "service_backend_config_port" "grpc" {
+  metadata {
+    name      = "gateway"
+    namespace = "default"
+  }
+
+  port           = "8443"
+  backend_config = "grpc"
+}
@@ -0,0 +1,2 @@
+# Import by namespace, backend config name and label selector
+terraform import service_backend_config_selector.public default/security-policy/tier=public
@@ -0,0 +1,13 @@
+# Every public Service of the namespace uses the shared security policy, unless it
+# sets another default backend config itself.
+resource "service_backend_config_selector" "public" {
+  metadata {
+    namespace = "default"
+  }
+
+  selector = {
+    tier = "public"
+  }
+
+  backend_config = backend_config.security_policy.metadata[0].name
+}
@@ -0,0 +1,2 @@
+# Import the NEG annotation of a Service by namespace and Service name
+terraform import service_neg.example default/web
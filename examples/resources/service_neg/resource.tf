@@ -0,0 +1,15 @@
+resource "service_neg" "example" {
+  metadata {
+    name      = "web"
+    namespace = "default"
+  }
+
+  # Use container-native load balancing for the ingress of the Service.
+  ingress = true
+
+  # Also create a standalone NEG for port 443, for example for a gateway outside GKE.
+  exposed_port {
+    port = 443
+    name = "web-tls"
+  }
+}
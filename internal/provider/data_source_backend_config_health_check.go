@@ -0,0 +1,282 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Kinds of workloads whose readiness probe can be read.
+const (
+	workloadKindDeployment  = "Deployment"
+	workloadKindStatefulSet = "StatefulSet"
+	workloadKindService     = "Service"
+)
+
+// Kubernetes defaults of the probe settings left unset.
+const (
+	defaultProbePeriodSeconds    = 10
+	defaultProbeTimeoutSeconds   = 1
+	defaultProbeSuccessThreshold = 1
+	defaultProbeFailureThreshold = 3
+)
+
+func dataSourceBackendConfigHealthCheck() *schema.Resource {
+	return &schema.Resource{
+		Description: "Derives the settings of `spec.health_check` of a `backend_config` from the HTTP readiness probe of a workload, so that the health check of the load balancer tracks the probe. For a Service, the probe is read from the Deployment or StatefulSet whose Pods the Service selects, and reading fails when the Service selects the Pods of several of them.",
+		ReadContext: dataSourceBackendConfigHealthCheckRead,
+		Schema: map[string]*schema.Schema{
+			"kind": {
+				Type:         schema.TypeString,
+				Description:  "Kind of the workload: `Deployment`, `StatefulSet` or `Service`.",
+				Required:     true,
+				ValidateFunc: validateAttributeValueIsIn([]string{workloadKindDeployment, workloadKindStatefulSet, workloadKindService}),
+			},
+			"name": {
+				Type:         schema.TypeString,
+				Description:  "Name of the workload.",
+				Required:     true,
+				ValidateFunc: validateName,
+			},
+			"namespace": {
+				Type:         schema.TypeString,
+				Description:  "Namespace of the workload. Defaults to the provider's `namespace`.",
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validateNamespaceName,
+			},
+			"container": {
+				Type:        schema.TypeString,
+				Description: "Name of the container whose readiness probe is read. Defaults to the first container with a readiness probe.",
+				Optional:    true,
+			},
+			"type": {
+				Type:        schema.TypeString,
+				Description: "`HTTPS` when the probe uses the HTTPS scheme, `HTTP` otherwise.",
+				Computed:    true,
+			},
+			"port": {
+				Type:        schema.TypeInt,
+				Description: "The container port probed, named ports resolved to their number.",
+				Computed:    true,
+			},
+			"request_path": {
+				Type:        schema.TypeString,
+				Description: "The path probed, `/` when unset.",
+				Computed:    true,
+			},
+			"check_interval_sec": {
+				Type:        schema.TypeInt,
+				Description: "The `periodSeconds` of the probe.",
+				Computed:    true,
+			},
+			"timeout_sec": {
+				Type:        schema.TypeInt,
+				Description: "The `timeoutSeconds` of the probe.",
+				Computed:    true,
+			},
+			"healthy_threshold": {
+				Type:        schema.TypeInt,
+				Description: "The `successThreshold` of the probe.",
+				Computed:    true,
+			},
+			"unhealthy_threshold": {
+				Type:        schema.TypeInt,
+				Description: "The `failureThreshold` of the probe.",
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func dataSourceBackendConfigHealthCheckRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	kind := d.Get("kind").(string)
+	name := d.Get("name").(string)
+	namespace := d.Get("namespace").(string)
+	if namespace == "" {
+		namespace = meta.(*apiClient).namespace
+	}
+	if err := meta.(*apiClient).checkNamespaceAllowed(namespace); err != nil {
+		return diag.Errorf("namespace: %s", err)
+	}
+
+	log.Printf("[INFO] Reading readiness probe of %s %s/%s", kind, namespace, name)
+	template, err := workloadPodTemplate(ctx, conn, kind, namespace, name)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	hc, err := healthCheckFromPodTemplate(template, d.Get("container").(string))
+	if err != nil {
+		return diag.Errorf("%s %s/%s: %s", kind, namespace, name, err)
+	}
+
+	attrs := map[string]interface{}{
+		"namespace":           namespace,
+		"type":                *hc.Type,
+		"port":                *hc.Port,
+		"request_path":        *hc.RequestPath,
+		"check_interval_sec":  *hc.CheckIntervalSec,
+		"timeout_sec":         *hc.TimeoutSec,
+		"healthy_threshold":   *hc.HealthyThreshold,
+		"unhealthy_threshold": *hc.UnhealthyThreshold,
+	}
+	for k, v := range attrs {
+		if err := d.Set(k, v); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	d.SetId(strings.ToLower(kind) + "/" + buildId(metav1.ObjectMeta{Namespace: namespace, Name: name}))
+	return nil
+}
+
+// workloadPodTemplate returns the Pod template of a Deployment or StatefulSet, or for a
+// Service the one of the single Deployment or StatefulSet whose Pods it selects. A Service
+// selecting the Pods of no workload, or of several, is an error.
+func workloadPodTemplate(ctx context.Context, conn kubernetes.Interface, kind, namespace, name string) (corev1.PodTemplateSpec, error) {
+	switch kind {
+	case workloadKindDeployment:
+		deployment, err := conn.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return corev1.PodTemplateSpec{}, err
+		}
+		return deployment.Spec.Template, nil
+	case workloadKindStatefulSet:
+		statefulSet, err := conn.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return corev1.PodTemplateSpec{}, err
+		}
+		return statefulSet.Spec.Template, nil
+	}
+
+	svc, err := conn.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return corev1.PodTemplateSpec{}, err
+	}
+	if len(svc.Spec.Selector) == 0 {
+		return corev1.PodTemplateSpec{}, fmt.Errorf("service %s/%s has no selector", namespace, name)
+	}
+	selector := labels.SelectorFromSet(svc.Spec.Selector)
+
+	deployments, err := conn.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return corev1.PodTemplateSpec{}, err
+	}
+	statefulSets, err := conn.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return corev1.PodTemplateSpec{}, err
+	}
+	workloads := map[string]corev1.PodTemplateSpec{}
+	for _, w := range deployments.Items {
+		workloads[workloadKindDeployment+" "+w.Name] = w.Spec.Template
+	}
+	for _, w := range statefulSets.Items {
+		workloads[workloadKindStatefulSet+" "+w.Name] = w.Spec.Template
+	}
+	return selectPodTemplate(workloads, selector, fmt.Sprintf("service %s/%s", namespace, name))
+}
+
+func selectPodTemplate(workloads map[string]corev1.PodTemplateSpec, selector labels.Selector, selectedBy string) (corev1.PodTemplateSpec, error) {
+	keys := make([]string, 0, len(workloads))
+	for k := range workloads {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var matches []string
+	for _, k := range keys {
+		if selector.Matches(labels.Set(workloads[k].Labels)) {
+			matches = append(matches, k)
+		}
+	}
+	if len(matches) == 0 {
+		return corev1.PodTemplateSpec{}, fmt.Errorf("no Deployment or StatefulSet has Pods selected by %s", selectedBy)
+	}
+	if len(matches) > 1 {
+		return corev1.PodTemplateSpec{}, fmt.Errorf("Pods of %s are all selected by %s, read the readiness probe of one of them by kind and name instead", strings.Join(matches, ", "), selectedBy)
+	}
+	return workloads[matches[0]], nil
+}
+
+// healthCheckFromPodTemplate converts the HTTP readiness probe of the named container, or
+// of the first container with a readiness probe, to health check settings.
+func healthCheckFromPodTemplate(template corev1.PodTemplateSpec, container string) (healthCheckConfig, error) {
+	var c *corev1.Container
+	for i := range template.Spec.Containers {
+		candidate := &template.Spec.Containers[i]
+		if (container == "" && candidate.ReadinessProbe != nil) || (container != "" && candidate.Name == container) {
+			c = candidate
+			break
+		}
+	}
+	switch {
+	case c == nil && container != "":
+		return healthCheckConfig{}, fmt.Errorf("no container named %q", container)
+	case c == nil:
+		return healthCheckConfig{}, fmt.Errorf("no container has a readiness probe")
+	case c.ReadinessProbe == nil:
+		return healthCheckConfig{}, fmt.Errorf("container %q has no readiness probe", c.Name)
+	case c.ReadinessProbe.HTTPGet == nil:
+		return healthCheckConfig{}, fmt.Errorf("the readiness probe of container %q is not an HTTP probe, which a backendconfig health check cannot reproduce", c.Name)
+	}
+	probe := c.ReadinessProbe
+	httpGet := probe.HTTPGet
+
+	port, err := containerPortNumber(*c, httpGet.Port)
+	if err != nil {
+		return healthCheckConfig{}, err
+	}
+	healthCheckType := "HTTP"
+	if httpGet.Scheme == corev1.URISchemeHTTPS {
+		healthCheckType = "HTTPS"
+	}
+	requestPath := httpGet.Path
+	if requestPath == "" {
+		requestPath = "/"
+	}
+	withDefault := func(v int32, defaultValue int64) *int64 {
+		if v == 0 {
+			return ptrToInt64(defaultValue)
+		}
+		return ptrToInt64(int64(v))
+	}
+
+	return healthCheckConfig{
+		Type:               ptrToString(healthCheckType),
+		Port:               ptrToInt64(int64(port)),
+		RequestPath:        ptrToString(requestPath),
+		CheckIntervalSec:   withDefault(probe.PeriodSeconds, defaultProbePeriodSeconds),
+		TimeoutSec:         withDefault(probe.TimeoutSeconds, defaultProbeTimeoutSeconds),
+		HealthyThreshold:   withDefault(probe.SuccessThreshold, defaultProbeSuccessThreshold),
+		UnhealthyThreshold: withDefault(probe.FailureThreshold, defaultProbeFailureThreshold),
+	}, nil
+}
+
+// containerPortNumber resolves the port of a probe, which may be the name of a port of the
+// container.
+func containerPortNumber(c corev1.Container, port intstr.IntOrString) (int32, error) {
+	if port.Type == intstr.Int {
+		return port.IntVal, nil
+	}
+	for _, p := range c.Ports {
+		if p.Name == port.StrVal {
+			return p.ContainerPort, nil
+		}
+	}
+	return 0, fmt.Errorf("container %q has no port named %q", c.Name, port.StrVal)
+}
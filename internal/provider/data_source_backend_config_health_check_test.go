@@ -0,0 +1,207 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestHealthCheckFromPodTemplate(t *testing.T) {
+	template := func(probe *corev1.Probe) corev1.PodTemplateSpec {
+		return corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: "proxy"},
+			{
+				Name:           "web",
+				Ports:          []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}},
+				ReadinessProbe: probe,
+			},
+		}}}
+	}
+
+	cases := map[string]struct {
+		probe       *corev1.Probe
+		container   string
+		expected    healthCheckConfig
+		errContains string
+	}{
+		"defaults": {
+			probe: &corev1.Probe{Handler: corev1.Handler{HTTPGet: &corev1.HTTPGetAction{Port: intstr.FromInt(8080)}}},
+			expected: healthCheckConfig{
+				Type:               ptrToString("HTTP"),
+				Port:               ptrToInt64(8080),
+				RequestPath:        ptrToString("/"),
+				CheckIntervalSec:   ptrToInt64(10),
+				TimeoutSec:         ptrToInt64(1),
+				HealthyThreshold:   ptrToInt64(1),
+				UnhealthyThreshold: ptrToInt64(3),
+			},
+		},
+		"named port and HTTPS": {
+			probe: &corev1.Probe{
+				Handler: corev1.Handler{HTTPGet: &corev1.HTTPGetAction{
+					Path:   "/healthz",
+					Port:   intstr.FromString("http"),
+					Scheme: corev1.URISchemeHTTPS,
+				}},
+				PeriodSeconds:    15,
+				TimeoutSeconds:   5,
+				SuccessThreshold: 2,
+				FailureThreshold: 4,
+			},
+			container: "web",
+			expected: healthCheckConfig{
+				Type:               ptrToString("HTTPS"),
+				Port:               ptrToInt64(8080),
+				RequestPath:        ptrToString("/healthz"),
+				CheckIntervalSec:   ptrToInt64(15),
+				TimeoutSec:         ptrToInt64(5),
+				HealthyThreshold:   ptrToInt64(2),
+				UnhealthyThreshold: ptrToInt64(4),
+			},
+		},
+		"unknown named port": {
+			probe:       &corev1.Probe{Handler: corev1.Handler{HTTPGet: &corev1.HTTPGetAction{Port: intstr.FromString("metrics")}}},
+			errContains: `no port named "metrics"`,
+		},
+		"TCP probe": {
+			probe:       &corev1.Probe{Handler: corev1.Handler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(8080)}}},
+			errContains: "not an HTTP probe",
+		},
+		"container without probe": {
+			probe:       &corev1.Probe{Handler: corev1.Handler{HTTPGet: &corev1.HTTPGetAction{Port: intstr.FromInt(8080)}}},
+			container:   "proxy",
+			errContains: `container "proxy" has no readiness probe`,
+		},
+		"no probe": {
+			errContains: "no container has a readiness probe",
+		},
+	}
+
+	for name, tc := range cases {
+		actual, err := healthCheckFromPodTemplate(template(tc.probe), tc.container)
+		if tc.errContains != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.errContains) {
+				t.Errorf("%s: expected an error containing %q, got %v", name, tc.errContains, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", name, err)
+			continue
+		}
+		if got, want := formatFieldValue(actual), formatFieldValue(tc.expected); got != want {
+			t.Errorf("%s: expected %s, got %s", name, want, got)
+		}
+	}
+}
+
+func TestBackendConfigHealthCheckDataSourceService(t *testing.T) {
+	podTemplate := func(app string, port int) corev1.PodTemplateSpec {
+		return corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": app}},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name: app,
+				ReadinessProbe: &corev1.Probe{Handler: corev1.Handler{HTTPGet: &corev1.HTTPGetAction{
+					Path: "/ready",
+					Port: intstr.FromInt(port),
+				}}},
+			}}},
+		}
+	}
+	meta := &apiClient{
+		namespace: "default",
+		clientset: fake.NewSimpleClientset(
+			&corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+				Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "web"}},
+			},
+			&appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+				Spec:       appsv1.DeploymentSpec{Template: podTemplate("api", 9000)},
+			},
+			&appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+				Spec:       appsv1.StatefulSetSpec{Template: podTemplate("web", 8080)},
+			},
+		),
+	}
+
+	d := schema.TestResourceDataRaw(t, dataSourceBackendConfigHealthCheck().Schema, map[string]interface{}{
+		"kind": workloadKindService,
+		"name": "web",
+	})
+	if diags := dataSourceBackendConfigHealthCheckRead(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected error: %#v", diags)
+	}
+	if port, path := d.Get("port").(int), d.Get("request_path").(string); port != 8080 || path != "/ready" {
+		t.Errorf("expected the probe of the StatefulSet, got port %d and path %s", port, path)
+	}
+	if d.Id() != "service/default/web" {
+		t.Errorf("unexpected ID %q", d.Id())
+	}
+}
+
+func TestBackendConfigHealthCheckDataSourceErrors(t *testing.T) {
+	podTemplate := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web"}},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name: "web",
+			ReadinessProbe: &corev1.Probe{Handler: corev1.Handler{HTTPGet: &corev1.HTTPGetAction{
+				Port: intstr.FromInt(8080),
+			}}},
+		}}},
+	}
+	meta := &apiClient{
+		namespace:         "default",
+		allowedNamespaces: []string{"default"},
+		clientset: fake.NewSimpleClientset(
+			&corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+				Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "web"}},
+			},
+			&appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+				Spec:       appsv1.DeploymentSpec{Template: podTemplate},
+			},
+			&appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "web-canary", Namespace: "default"},
+				Spec:       appsv1.StatefulSetSpec{Template: podTemplate},
+			},
+		),
+	}
+
+	cases := map[string]struct {
+		raw         map[string]interface{}
+		errContains []string
+	}{
+		"several workloads": {
+			raw:         map[string]interface{}{"kind": workloadKindService, "name": "web"},
+			errContains: []string{"Deployment web", "StatefulSet web-canary"},
+		},
+		"namespace not allowed": {
+			raw:         map[string]interface{}{"kind": workloadKindDeployment, "name": "web", "namespace": "kube-system"},
+			errContains: []string{`namespace "kube-system" is not in the provider's allowed_namespaces`},
+		},
+	}
+
+	for name, tc := range cases {
+		d := schema.TestResourceDataRaw(t, dataSourceBackendConfigHealthCheck().Schema, tc.raw)
+		diags := dataSourceBackendConfigHealthCheckRead(context.Background(), d, meta)
+		if !diags.HasError() {
+			t.Errorf("%s: expected an error", name)
+			continue
+		}
+		for _, s := range tc.errContains {
+			if !strings.Contains(diags[0].Summary, s) {
+				t.Errorf("%s: expected an error containing %q, got %q", name, s, diags[0].Summary)
+			}
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package provider
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// suppressEquivalentCase hides diffs of enum values that the API stores in a canonical case.
+func suppressEquivalentCase(k, old, new string, d *schema.ResourceData) bool {
+	return strings.EqualFold(old, new)
+}
+
+// suppressEquivalentNumber hides diffs between nullable numbers stored as strings that
+// only differ in their notation, such as "0.5" and "0.50".
+func suppressEquivalentNumber(k, old, new string, d *schema.ResourceData) bool {
+	if old == "" || new == "" {
+		return old == new
+	}
+	o, err := strconv.ParseFloat(old, 64)
+	if err != nil {
+		return false
+	}
+	n, err := strconv.ParseFloat(new, 64)
+	if err != nil {
+		return false
+	}
+	return o == n
+}
@@ -0,0 +1,25 @@
+package provider
+
+import (
+	"testing"
+)
+
+func TestSuppressEquivalentCase(t *testing.T) {
+	cases := []struct {
+		old, new string
+		suppress bool
+	}{
+		{"HTTP", "HTTP", true},
+		{"HTTP", "http", true},
+		{"generated_cookie", "GENERATED_COOKIE", true},
+		{"HTTP", "HTTPS", false},
+		{"", "HTTP", false},
+		{"HTTP2", "http", false},
+	}
+
+	for _, tc := range cases {
+		if suppress := suppressEquivalentCase("type", tc.old, tc.new, nil); suppress != tc.suppress {
+			t.Errorf("%q to %q: expected suppress %t, got %t", tc.old, tc.new, tc.suppress, suppress)
+		}
+	}
+}
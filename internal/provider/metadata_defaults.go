@@ -0,0 +1,266 @@
+package provider
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Values of annotations_mode.
+const (
+	annotationsModeAuthoritative = "authoritative"
+	annotationsModeAdditive      = "additive"
+)
+
+// metadataManagementFields are the attributes controlling how the labels and annotations of
+// an object are managed. effective_labels and effective_annotations hold the ones applied to
+// the object: the provider's default_labels and default_annotations merged with
+// metadata.labels and metadata.annotations, the latter taking precedence.
+func metadataManagementFields(objectName string) map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"annotations_mode": {
+			Type:         schema.TypeString,
+			Description:  fmt.Sprintf("How the annotations of the %s are managed. `authoritative` removes annotations missing from `metadata.annotations`, `additive` only manages the declared keys and preserves any other annotation on the live object.", objectName),
+			Optional:     true,
+			Default:      annotationsModeAuthoritative,
+			ValidateFunc: validateAttributeValueIsIn([]string{annotationsModeAuthoritative, annotationsModeAdditive}),
+		},
+		"effective_labels": {
+			Type:        schema.TypeMap,
+			Description: fmt.Sprintf("All labels applied to the %s: the provider's `default_labels` merged with `metadata.labels`.", objectName),
+			Computed:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+		},
+		"effective_annotations": {
+			Type:        schema.TypeMap,
+			Description: fmt.Sprintf("All annotations applied to the %s: the provider's `default_annotations` merged with `metadata.annotations`.", objectName),
+			Computed:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+		},
+	}
+}
+
+func (c *apiClient) defaultMetadata(k string) map[string]string {
+	if k == "labels" {
+		return c.defaultLabels
+	}
+	return c.defaultAnnotations
+}
+
+func (c *apiClient) ignoredMetadata(k string) []*regexp.Regexp {
+	if k == "labels" {
+		return c.ignoreLabels
+	}
+	return c.ignoreAnnotations
+}
+
+// customizeDiffEffectiveMetadata plans effective_labels and effective_annotations, so that
+// the values inherited from the provider show up in the plan, and rejects keys the provider
+// is configured to ignore.
+func customizeDiffEffectiveMetadata(d *schema.ResourceDiff, meta interface{}) error {
+	for _, k := range []string{"labels", "annotations"} {
+		if !d.NewValueKnown("metadata.0." + k) {
+			if err := d.SetNewComputed("effective_" + k); err != nil {
+				return err
+			}
+			continue
+		}
+		for key := range d.Get("metadata.0." + k).(map[string]interface{}) {
+			if matchesAny(key, meta.(*apiClient).ignoredMetadata(k)) {
+				return fmt.Errorf("metadata.0.%s: %q matches a pattern in the provider's ignore_%s", k, key, k)
+			}
+		}
+		merged := mergeStringMaps(meta.(*apiClient).defaultMetadata(k), d.Get("metadata.0."+k).(map[string]interface{}))
+		if err := d.SetNew("effective_"+k, merged); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// customizeDiffNamespace fails the plan when the object is in a namespace the provider is not
+// allowed to manage objects in. The provider namespace is only checked when the object falls
+// back to it.
+func customizeDiffNamespace(d *schema.ResourceDiff, meta interface{}) error {
+	namespace, ok := plannedNamespace(d, meta)
+	if !ok {
+		return nil
+	}
+	if err := meta.(*apiClient).checkNamespaceAllowed(namespace); err != nil {
+		return fmt.Errorf("metadata.0.namespace: %s", err)
+	}
+	return nil
+}
+
+// plannedNamespace returns the namespace the object will be in, falling back to the provider
+// namespace, and whether it is known at plan time.
+func plannedNamespace(d *schema.ResourceDiff, meta interface{}) (string, bool) {
+	namespace := d.Get("metadata.0.namespace").(string)
+	if !d.NewValueKnown("metadata.0.namespace") {
+		// A computed namespace is unknown both when it is not set and when it is set from a
+		// value known only at apply time.
+		if !isNamespaceUnset(d.GetRawConfig()) {
+			return "", false
+		}
+		namespace = ""
+	}
+	if namespace == "" {
+		namespace = meta.(*apiClient).namespace
+	}
+	return namespace, true
+}
+
+// isNamespaceUnset tells whether config leaves metadata.namespace unset. It is false when
+// config is not available.
+func isNamespaceUnset(config cty.Value) bool {
+	if config.IsNull() || !config.IsKnown() || !config.Type().IsObjectType() || !config.Type().HasAttribute("metadata") {
+		return false
+	}
+	metadata := config.GetAttr("metadata")
+	if metadata.IsNull() || !metadata.IsKnown() || metadata.LengthInt() == 0 {
+		return false
+	}
+	block := metadata.Index(cty.NumberIntVal(0))
+	if block.IsNull() || !block.IsKnown() {
+		return false
+	}
+	return block.GetAttr("namespace").IsNull()
+}
+
+// checkNamespaceAllowed matches namespace against the provider's allowed_namespaces.
+func (c *apiClient) checkNamespaceAllowed(namespace string) error {
+	if len(c.allowedNamespaces) == 0 {
+		return nil
+	}
+	for _, pattern := range c.allowedNamespaces {
+		if ok, _ := path.Match(pattern, namespace); ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("namespace %q is not in the provider's allowed_namespaces %q", namespace, c.allowedNamespaces)
+}
+
+// expandEffectiveMetadata expands metadata with the provider defaults merged in.
+func expandEffectiveMetadata(d *schema.ResourceData, meta interface{}) metav1.ObjectMeta {
+	m := expandMetadata(d.Get("metadata").([]interface{}))
+	if m.Namespace == "" {
+		m.Namespace = meta.(*apiClient).namespace
+	}
+	m.Labels = expandStringMap(effectiveMetadataValue(d, meta, "labels"))
+	m.Annotations = expandStringMap(effectiveMetadataValue(d, meta, "annotations"))
+	if len(m.Labels) == 0 {
+		m.Labels = nil
+	}
+	if len(m.Annotations) == 0 {
+		m.Annotations = nil
+	}
+	return m
+}
+
+// effectiveMetadataChange returns the labels or annotations known to the last refresh and
+// the ones to apply now, to be patched onto the live object with patchStringMap. Keys that
+// were known but are no longer configured are removed.
+func effectiveMetadataChange(d *schema.ResourceData, meta interface{}, k string) (map[string]interface{}, map[string]interface{}) {
+	effective, _ := d.GetChange("effective_" + k)
+	configured, _ := d.GetChange("metadata.0." + k)
+	o := mergeStringMaps(expandStringMap(effective.(map[string]interface{})), configured.(map[string]interface{}))
+	return o, effectiveMetadataValue(d, meta, k)
+}
+
+func effectiveMetadataValue(d *schema.ResourceData, meta interface{}, k string) map[string]interface{} {
+	return mergeStringMaps(meta.(*apiClient).defaultMetadata(k), d.Get("metadata.0."+k).(map[string]interface{}))
+}
+
+// setEffectiveMetadata sets metadata and the effective_* attributes from the live object.
+// Ignored keys and keys inherited from the provider defaults are left out of metadata, as
+// are unknown annotations in additive mode. Only the keys managed through Terraform are kept
+// in effective_labels and effective_annotations.
+func setEffectiveMetadata(d *schema.ResourceData, meta interface{}, m metav1.ObjectMeta) error {
+	c := meta.(*apiClient)
+	configLabels := d.Get("metadata.0.labels").(map[string]interface{})
+	configAnnotations := d.Get("metadata.0.annotations").(map[string]interface{})
+
+	effectiveLabels := filterStringMap(m.Labels, c.defaultLabels, configLabels)
+	effectiveAnnotations := filterStringMap(m.Annotations, c.defaultAnnotations, configAnnotations)
+
+	m.Labels = removeDefaultKeys(removeIgnoredKeys(m.Labels, c.ignoreLabels), c.defaultLabels, configLabels)
+	m.Annotations = removeDefaultKeys(removeIgnoredKeys(m.Annotations, c.ignoreAnnotations), c.defaultAnnotations, configAnnotations)
+
+	mode := d.Get("annotations_mode").(string)
+	if mode == "" {
+		// Imported, or created before annotations_mode existed.
+		mode = annotationsModeAuthoritative
+		if err := d.Set("annotations_mode", mode); err != nil {
+			return err
+		}
+	}
+	if mode == annotationsModeAdditive {
+		m.Annotations = filterStringMap(m.Annotations, nil, configAnnotations)
+	}
+
+	if err := d.Set("metadata", flattenMetadata(m, d)); err != nil {
+		return err
+	}
+	if err := d.Set("effective_labels", effectiveLabels); err != nil {
+		return err
+	}
+	return d.Set("effective_annotations", effectiveAnnotations)
+}
+
+// mergeStringMaps returns defaults overridden by the values in m.
+func mergeStringMaps(defaults map[string]string, m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(defaults)+len(m))
+	for k, v := range defaults {
+		out[k] = v
+	}
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+// filterStringMap returns the entries of m whose key is in defaults or configured.
+func filterStringMap(m map[string]string, defaults map[string]string, configured map[string]interface{}) map[string]string {
+	out := make(map[string]string)
+	for k, v := range m {
+		_, isDefault := defaults[k]
+		if isDefault || isKeyInMap(k, configured) {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// removeDefaultKeys returns a copy of m without the keys coming from defaults, unless they
+// are also configured on the resource.
+func removeDefaultKeys(m map[string]string, defaults map[string]string, configured map[string]interface{}) map[string]string {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		if _, isDefault := defaults[k]; isDefault && !isKeyInMap(k, configured) {
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// removeIgnoredKeys returns a copy of m without the keys matching one of the patterns.
+func removeIgnoredKeys(m map[string]string, patterns []*regexp.Regexp) map[string]string {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		if !matchesAny(k, patterns) {
+			out[k] = v
+		}
+	}
+	return out
+}
@@ -0,0 +1,287 @@
+package provider
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+	ctyjson "github.com/hashicorp/go-cty/cty/json"
+	"github.com/hashicorp/go-cty/cty/msgpack"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestEffectiveMetadataPatch refreshes a backend config whose live object carries keys
+// written by controllers, then applies the same configuration and checks which keys the
+// update keeps.
+func TestEffectiveMetadataPatch(t *testing.T) {
+	meta := &apiClient{
+		defaultLabels:     map[string]string{"team": "platform"},
+		ignoreLabels:      []*regexp.Regexp{regexp.MustCompile(`^argocd\.argoproj\.io/`)},
+		ignoreAnnotations: []*regexp.Regexp{regexp.MustCompile(`^cloud\.google\.com/`)},
+	}
+	live := metav1.ObjectMeta{
+		Name:      "web",
+		Namespace: "default",
+		Labels: map[string]string{
+			"app":                         "web",
+			"team":                        "platform",
+			"argocd.argoproj.io/instance": "web",
+			"deployed-by":                 "pipeline",
+		},
+		Annotations: map[string]string{
+			"example.com/owner":               "web",
+			"cloud.google.com/neg-status":     "{}",
+			"controller.example.com/revision": "3",
+		},
+	}
+
+	cases := map[string]struct {
+		mode                string
+		expectedLabels      map[string]string
+		expectedAnnotations map[string]string
+	}{
+		annotationsModeAuthoritative: {
+			mode: annotationsModeAuthoritative,
+			expectedLabels: map[string]string{
+				"app":                         "web",
+				"team":                        "platform",
+				"argocd.argoproj.io/instance": "web",
+			},
+			expectedAnnotations: map[string]string{
+				"example.com/owner":           "web",
+				"cloud.google.com/neg-status": "{}",
+			},
+		},
+		annotationsModeAdditive: {
+			mode: annotationsModeAdditive,
+			expectedLabels: map[string]string{
+				"app":                         "web",
+				"team":                        "platform",
+				"argocd.argoproj.io/instance": "web",
+			},
+			expectedAnnotations: map[string]string{
+				"example.com/owner":               "web",
+				"cloud.google.com/neg-status":     "{}",
+				"controller.example.com/revision": "3",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			r := resourceBackendConfig()
+			config := terraform.NewResourceConfigRaw(map[string]interface{}{
+				"annotations_mode": tc.mode,
+				"metadata": []interface{}{map[string]interface{}{
+					"name":        "web",
+					"namespace":   "default",
+					"labels":      map[string]interface{}{"app": "web"},
+					"annotations": map[string]interface{}{"example.com/owner": "web"},
+				}},
+				"spec": []interface{}{map[string]interface{}{}},
+			})
+
+			// Refresh the state of an existing object.
+			state := &terraform.InstanceState{ID: "default/web", Attributes: map[string]string{"annotations_mode": tc.mode}}
+			d := r.Data(state)
+			if err := setEffectiveMetadata(d, meta, *live.DeepCopy()); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := d.Get("metadata.0.labels").(map[string]interface{})["argocd.argoproj.io/instance"]; ok {
+				t.Errorf("ignored label read into state")
+			}
+			if _, ok := d.Get("metadata.0.labels").(map[string]interface{})["team"]; ok {
+				t.Errorf("default label read into metadata")
+			}
+
+			// Apply the configuration on top of the refreshed state.
+			diff, err := r.Diff(ctx, d.State(), config, meta)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, err = schema.InternalMap(r.Schema).Data(d.State(), diff)
+			if err != nil {
+				t.Fatal(err)
+			}
+			updated := live.DeepCopy()
+			o, n := effectiveMetadataChange(d, meta, "labels")
+			updated.Labels = patchStringMap(updated.Labels, o, n)
+			o, n = effectiveMetadataChange(d, meta, "annotations")
+			updated.Annotations = patchStringMap(updated.Annotations, o, n)
+
+			if !reflect.DeepEqual(updated.Labels, tc.expectedLabels) {
+				t.Errorf("labels: expected %v, got %v", tc.expectedLabels, updated.Labels)
+			}
+			if !reflect.DeepEqual(updated.Annotations, tc.expectedAnnotations) {
+				t.Errorf("annotations: expected %v, got %v", tc.expectedAnnotations, updated.Annotations)
+			}
+		})
+	}
+}
+
+func TestEffectiveMetadataRejectsIgnoredKeys(t *testing.T) {
+	meta := &apiClient{ignoreAnnotations: []*regexp.Regexp{regexp.MustCompile(`^cloud\.google\.com/`)}}
+	config := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"metadata": []interface{}{map[string]interface{}{
+			"name":        "web",
+			"annotations": map[string]interface{}{"cloud.google.com/neg": "{}"},
+		}},
+		"spec": []interface{}{map[string]interface{}{}},
+	})
+
+	if _, err := resourceBackendConfig().Diff(context.Background(), nil, config, meta); err == nil {
+		t.Fatal("expected an error for an annotation matching ignore_annotations")
+	}
+}
+
+func TestNamespaceAllowlist(t *testing.T) {
+	meta := &apiClient{namespace: "team-a", allowedNamespaces: []string{"team-a", "team-a-*"}}
+	cases := map[string]bool{
+		"":             true,
+		"team-a":       true,
+		"team-a-batch": true,
+		"default":      false,
+		"team-b":       false,
+	}
+
+	for namespace, allowed := range cases {
+		m := map[string]interface{}{"name": "web"}
+		if namespace != "" {
+			m["namespace"] = namespace
+		}
+		config := terraform.NewResourceConfigRaw(map[string]interface{}{
+			"metadata": []interface{}{m},
+			"spec":     []interface{}{map[string]interface{}{}},
+		})
+
+		_, err := resourceBackendConfig().Diff(context.Background(), nil, config, meta)
+		if allowed && err != nil {
+			t.Errorf("%q: unexpected error: %s", namespace, err)
+		}
+		if !allowed && err == nil {
+			t.Errorf("%q: expected an error", namespace)
+		}
+	}
+}
+
+// TestNamespaceAllowlistProviderNamespace plans backend configs through the same path
+// Terraform uses and checks that the provider namespace is only checked against
+// allowed_namespaces for the objects that fall back to it.
+func TestNamespaceAllowlistProviderNamespace(t *testing.T) {
+	ctx := context.Background()
+	p := New("dev")()
+	diags := p.Configure(ctx, terraform.NewResourceConfigRaw(map[string]interface{}{
+		"allowed_namespaces": []interface{}{"team-a"},
+	}))
+	if diags.HasError() {
+		t.Fatalf("unexpected error: %#v", diags)
+	}
+
+	cases := map[string]struct {
+		namespace cty.Value
+		allowed   bool
+	}{
+		"unset":              {cty.NullVal(cty.String), false},
+		"allowed":            {cty.StringVal("team-a"), true},
+		"provider namespace": {cty.StringVal("default"), false},
+		"unknown":            {cty.UnknownVal(cty.String), true},
+	}
+
+	for name, tc := range cases {
+		config := testBackendConfigValue(t, `{"metadata": [{"name": "web"}], "spec": [{}]}`)
+		config, err := cty.Transform(config, func(path cty.Path, v cty.Value) (cty.Value, error) {
+			if path.Equals(cty.GetAttrPath("metadata").IndexInt(0).GetAttr("namespace")) {
+				return tc.namespace, nil
+			}
+			return v, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		errs := testPlanBackendConfig(t, p, config)
+		if tc.allowed && len(errs) > 0 {
+			t.Errorf("%s: unexpected errors: %q", name, errs)
+		}
+		if !tc.allowed && (len(errs) == 0 || !strings.Contains(errs[0], `namespace "default" is not in`)) {
+			t.Errorf("%s: expected the default namespace to be refused, got %q", name, errs)
+		}
+	}
+}
+
+// TestNamespaceAllowlistServiceResources plans every resource annotating a Service in an
+// allowed and a refused namespace.
+func TestNamespaceAllowlistServiceResources(t *testing.T) {
+	meta := testServiceAnnotationClient(testService(nil), "web")
+	meta.allowedNamespaces = []string{"default"}
+	cases := map[string]struct {
+		resource *schema.Resource
+		raw      map[string]interface{}
+	}{
+		"service_app_protocols":       {resourceServiceAppProtocols(), map[string]interface{}{"app_protocols": map[string]interface{}{"http": "HTTP"}}},
+		"service_backend_config":      {resourceServiceBackendConfig(), map[string]interface{}{"default": "web"}},
+		"service_backend_config_port": {resourceServiceBackendConfigPort(), map[string]interface{}{"port": "http", "backend_config": "web"}},
+		"service_neg":                 {resourceServiceNEG(), map[string]interface{}{"ingress": true}},
+	}
+
+	for name, tc := range cases {
+		for namespace, allowed := range map[string]bool{"default": true, "team-b": false} {
+			tc.raw["metadata"] = []interface{}{map[string]interface{}{"name": "web", "namespace": namespace}}
+			_, err := tc.resource.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(tc.raw), meta)
+			if allowed && err != nil {
+				t.Errorf("%s in %s: unexpected error: %s", name, namespace, err)
+			}
+			if !allowed && (err == nil || !strings.Contains(err.Error(), `namespace "team-b" is not in`)) {
+				t.Errorf("%s in %s: expected the namespace to be refused, got %v", name, namespace, err)
+			}
+		}
+	}
+}
+
+// testBackendConfigValue decodes a backend_config configuration written as JSON. Omitted
+// attributes are null.
+func testBackendConfigValue(t *testing.T, js string) cty.Value {
+	v, err := ctyjson.Unmarshal([]byte(js), resourceBackendConfig().CoreConfigSchema().ImpliedType())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// testPlanBackendConfig plans the creation of a backend_config through the gRPC server, as
+// Terraform does, so that CustomizeDiff can read the raw configuration. It returns the
+// summaries of the diagnostics.
+func testPlanBackendConfig(t *testing.T, p *schema.Provider, config cty.Value) []string {
+	ty := resourceBackendConfig().CoreConfigSchema().ImpliedType()
+	b, err := msgpack.Marshal(config, ty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prior, err := msgpack.Marshal(cty.NullVal(ty), ty)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := schema.NewGRPCProviderServer(p).PlanResourceChange(context.Background(), &tfprotov5.PlanResourceChangeRequest{
+		TypeName:         "backend_config",
+		PriorState:       &tfprotov5.DynamicValue{MsgPack: prior},
+		ProposedNewState: &tfprotov5.DynamicValue{MsgPack: b},
+		Config:           &tfprotov5.DynamicValue{MsgPack: b},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summaries []string
+	for _, d := range resp.Diagnostics {
+		summaries = append(summaries, d.Summary)
+	}
+	return summaries
+}
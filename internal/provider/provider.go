@@ -1,10 +1,23 @@
 package provider
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/mitchellh/go-homedir"
+	apimachineryschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
 func init() {
@@ -134,11 +147,72 @@ func New(version string) func() *schema.Provider {
 					},
 					Description: "",
 				},
+				"read_only": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("FEBECONFIG_READ_ONLY", false),
+					Description: "When true, the provider only reads from the cluster: refresh, import and data sources keep working, while every create, update and delete fails. Can be set with FEBECONFIG_READ_ONLY.",
+				},
+				"namespace": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					DefaultFunc:  schema.EnvDefaultFunc("KUBE_NAMESPACE", "default"),
+					ValidateFunc: validateNamespaceName,
+					Description:  "Namespace of the objects whose `metadata.namespace` is not set. Can be set with KUBE_NAMESPACE. Defaults to `default`.",
+				},
+				"allowed_namespaces": {
+					Type:        schema.TypeList,
+					Optional:    true,
+					Elem:        &schema.Schema{Type: schema.TypeString, ValidateFunc: validateGlob},
+					Description: "Glob patterns, such as `team-a-*`, of the namespaces the provider may manage objects in. Plans targeting any other namespace fail. All namespaces are allowed when unset.",
+				},
+				"default_labels": {
+					Type:         schema.TypeMap,
+					Optional:     true,
+					Elem:         &schema.Schema{Type: schema.TypeString},
+					ValidateFunc: validateLabels,
+					Description:  "Labels added to every object managed by the provider. Labels set in a resource's `metadata` take precedence.",
+				},
+				"default_annotations": {
+					Type:         schema.TypeMap,
+					Optional:     true,
+					Elem:         &schema.Schema{Type: schema.TypeString},
+					ValidateFunc: validateAnnotations,
+					Description:  "Annotations added to every object managed by the provider. Annotations set in a resource's `metadata` take precedence.",
+				},
+				"ignore_labels": {
+					Type:        schema.TypeList,
+					Optional:    true,
+					Elem:        &schema.Schema{Type: schema.TypeString, ValidateFunc: validateRegexp},
+					Description: "Regular expressions matching label keys the provider ignores, for example labels written by controllers. Matching keys are not read into state and never removed.",
+				},
+				"ignore_annotations": {
+					Type:        schema.TypeList,
+					Optional:    true,
+					Elem:        &schema.Schema{Type: schema.TypeString, ValidateFunc: validateRegexp},
+					Description: "Regular expressions matching annotation keys the provider ignores, for example `^cloud\\.google\\.com/`. Matching keys are not read into state and never removed.",
+				},
 			},
 			ResourcesMap: map[string]*schema.Resource{
-				"backend_config": resourceBackendConfig(),
+				"backend_config":                  resourceBackendConfig(),
+				"backend_config_signed_url_key":   resourceBackendConfigSignedURLKey(),
+				"service_app_protocols":           resourceServiceAppProtocols(),
+				"service_backend_config":          resourceServiceBackendConfig(),
+				"service_backend_config_port":     resourceServiceBackendConfigPort(),
+				"service_backend_config_selector": resourceServiceBackendConfigSelector(),
+				"service_neg":                     resourceServiceNEG(),
 				//"frontend_config": resourceFrontendConfig(),
 			},
+			DataSourcesMap: map[string]*schema.Resource{
+				"backend_config_health_check": dataSourceBackendConfigHealthCheck(),
+			},
+		}
+
+		for k, v := range refreshBehaviorFields() {
+			p.Schema[k] = v
+		}
+		for name, r := range p.ResourcesMap {
+			guardReadOnly(name, r)
 		}
 
 		p.ConfigureContextFunc = configure(version, p)
@@ -148,17 +222,248 @@ func New(version string) func() *schema.Provider {
 }
 
 type apiClient struct {
-	// Add whatever fields, client or connection info, etc. here
-	// you would need to setup to communicate with the upstream
-	// API.
+	config        *restclient.Config
+	clientset     kubernetes.Interface
+	dynamicClient dynamic.Interface
+	readOnly      bool
+
+	refreshUnreachableCluster   string
+	refreshMissingCRD           string
+	refreshTerminatingNamespace string
+
+	namespace          string
+	allowedNamespaces  []string
+	defaultLabels      map[string]string
+	defaultAnnotations map[string]string
+	ignoreLabels       []*regexp.Regexp
+	ignoreAnnotations  []*regexp.Regexp
+}
+
+func (c *apiClient) MainClientset() (kubernetes.Interface, error) {
+	if c.clientset != nil {
+		return c.clientset, nil
+	}
+	if c.config == nil {
+		return nil, fmt.Errorf("provider not configured: no valid Kubernetes client configuration was supplied")
+	}
+	clientset, err := kubernetes.NewForConfig(c.config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure client: %s", err)
+	}
+	c.clientset = clientset
+	return c.clientset, nil
+}
+
+func (c *apiClient) DynamicClient() (dynamic.Interface, error) {
+	if c.dynamicClient != nil {
+		return c.dynamicClient, nil
+	}
+	if c.config == nil {
+		return nil, fmt.Errorf("provider not configured: no valid Kubernetes client configuration was supplied")
+	}
+	dynamicClient, err := dynamic.NewForConfig(c.config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure dynamic client: %s", err)
+	}
+	c.dynamicClient = dynamicClient
+	return c.dynamicClient, nil
 }
 
 func configure(version string, p *schema.Provider) func(context.Context, *schema.ResourceData) (interface{}, diag.Diagnostics) {
-	return func(context.Context, *schema.ResourceData) (interface{}, diag.Diagnostics) {
-		// Setup a User-Agent for your API client (replace the provider name for yours):
-		// userAgent := p.UserAgent("terraform-provider-scaffolding", version)
-		// TODO: myClient.UserAgent = userAgent
-		//userAgent := p.UserAgent("terraform-provider-febeconfig", version)
-		return &apiClient{}, nil
+	return func(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
+		client := &apiClient{
+			readOnly:           d.Get("read_only").(bool),
+			namespace:          d.Get("namespace").(string),
+			allowedNamespaces:  expandStringSlice(d.Get("allowed_namespaces").([]interface{})),
+			defaultLabels:      expandStringMap(d.Get("default_labels").(map[string]interface{})),
+			defaultAnnotations: expandStringMap(d.Get("default_annotations").(map[string]interface{})),
+			ignoreLabels:       expandRegexpList(d.Get("ignore_labels").([]interface{})),
+			ignoreAnnotations:  expandRegexpList(d.Get("ignore_annotations").([]interface{})),
+
+			refreshUnreachableCluster:   d.Get("unreachable_cluster_behavior").(string),
+			refreshMissingCRD:           d.Get("missing_crd_behavior").(string),
+			refreshTerminatingNamespace: d.Get("terminating_namespace_behavior").(string),
+		}
+		for k := range client.defaultLabels {
+			if matchesAny(k, client.ignoreLabels) {
+				return nil, diag.Errorf("default_labels: %q matches a pattern in ignore_labels", k)
+			}
+		}
+		for k := range client.defaultAnnotations {
+			if matchesAny(k, client.ignoreAnnotations) {
+				return nil, diag.Errorf("default_annotations: %q matches a pattern in ignore_annotations", k)
+			}
+		}
+
+		cfg, err := initializeConfiguration(d)
+		if err != nil {
+			return nil, diag.FromErr(err)
+		}
+		if cfg == nil {
+			// The client is built lazily so that a missing configuration only
+			// fails the operations that actually need to talk to the cluster.
+			return client, nil
+		}
+
+		cfg.QPS = 100.0
+		cfg.Burst = 100
+		cfg.UserAgent = p.UserAgent("terraform-provider-febeconfig", version)
+		client.config = cfg
+
+		return client, nil
 	}
 }
+
+// guardReadOnly makes the create, update and delete functions of a resource fail when the
+// provider is read_only, before they reach the cluster.
+func guardReadOnly(name string, r *schema.Resource) {
+	guard := func(action string, f func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics) func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics {
+		return func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+			if meta.(*apiClient).readOnly {
+				target := d.Id()
+				if name, ok := d.Get("metadata.0.name").(string); ok && target == "" {
+					target = name
+				}
+				return diag.Diagnostics{{
+					Severity: diag.Error,
+					Summary:  "Provider is read-only",
+					Detail:   fmt.Sprintf("Cannot %s %s %q: the provider is configured with read_only = true, which only allows reading from the cluster.", action, name, target),
+				}}
+			}
+			return f(ctx, d, meta)
+		}
+	}
+
+	if r.CreateContext != nil {
+		r.CreateContext = guard("create", r.CreateContext)
+	}
+	if r.UpdateContext != nil {
+		r.UpdateContext = guard("update", r.UpdateContext)
+	}
+	if r.DeleteContext != nil {
+		r.DeleteContext = guard("delete", r.DeleteContext)
+	}
+}
+
+// stolen from https://github.com/hashicorp/terraform-provider-kubernetes/blob/master/kubernetes/provider.go
+func initializeConfiguration(d *schema.ResourceData) (*restclient.Config, error) {
+	overrides := &clientcmd.ConfigOverrides{}
+	loader := &clientcmd.ClientConfigLoadingRules{}
+
+	configPaths := []string{}
+	if v, ok := d.Get("config_path").(string); ok && v != "" {
+		configPaths = []string{v}
+	} else if v, ok := d.Get("config_paths").([]interface{}); ok && len(v) > 0 {
+		for _, p := range v {
+			configPaths = append(configPaths, p.(string))
+		}
+	} else if v := os.Getenv("KUBE_CONFIG_PATHS"); v != "" {
+		configPaths = filepath.SplitList(v)
+	}
+
+	if len(configPaths) > 0 {
+		expandedPaths := []string{}
+		for _, p := range configPaths {
+			path, err := homedir.Expand(p)
+			if err != nil {
+				return nil, err
+			}
+			log.Printf("[DEBUG] Using kubeconfig: %s", path)
+			expandedPaths = append(expandedPaths, path)
+		}
+
+		if len(expandedPaths) == 1 {
+			loader.ExplicitPath = expandedPaths[0]
+		} else {
+			loader.Precedence = expandedPaths
+		}
+
+		ctxSuffix := "; default context"
+
+		kubectx, ctxOk := d.GetOk("config_context")
+		authInfo, authInfoOk := d.GetOk("config_context_auth_info")
+		cluster, clusterOk := d.GetOk("config_context_cluster")
+		if ctxOk || authInfoOk || clusterOk {
+			ctxSuffix = "; overridden context"
+			if ctxOk {
+				overrides.CurrentContext = kubectx.(string)
+				ctxSuffix += fmt.Sprintf("; config ctx: %s", overrides.CurrentContext)
+				log.Printf("[DEBUG] Using custom current context: %q", overrides.CurrentContext)
+			}
+
+			overrides.Context = clientcmdapi.Context{}
+			if authInfoOk {
+				overrides.Context.AuthInfo = authInfo.(string)
+				ctxSuffix += fmt.Sprintf("; auth_info: %s", overrides.Context.AuthInfo)
+			}
+			if clusterOk {
+				overrides.Context.Cluster = cluster.(string)
+				ctxSuffix += fmt.Sprintf("; cluster: %s", overrides.Context.Cluster)
+			}
+			log.Printf("[DEBUG] Using overridden context: %#v", overrides.Context)
+		}
+		log.Printf("[DEBUG] Using kubeconfig%s", ctxSuffix)
+	}
+
+	// Overriding with static configuration
+	if v, ok := d.GetOk("insecure"); ok {
+		overrides.ClusterInfo.InsecureSkipTLSVerify = v.(bool)
+	}
+	if v, ok := d.GetOk("cluster_ca_certificate"); ok {
+		overrides.ClusterInfo.CertificateAuthorityData = bytes.NewBufferString(v.(string)).Bytes()
+	}
+	if v, ok := d.GetOk("client_certificate"); ok {
+		overrides.AuthInfo.ClientCertificateData = bytes.NewBufferString(v.(string)).Bytes()
+	}
+	if v, ok := d.GetOk("host"); ok {
+		// Server has to be the complete address of the kubernetes cluster (scheme://hostname:port), not just the hostname,
+		// because `overrides` are processed too late to be taken into account by `defaultServerUrlFor()`.
+		// This basically replicates what defaultServerUrlFor() does with config but for overrides,
+		// see https://github.com/kubernetes/client-go/blob/v12.0.0/rest/url_utils.go#L85-L87
+		hasCA := len(overrides.ClusterInfo.CertificateAuthorityData) != 0
+		hasCert := len(overrides.AuthInfo.ClientCertificateData) != 0
+		defaultTLS := hasCA || hasCert || overrides.ClusterInfo.InsecureSkipTLSVerify
+		host, _, err := restclient.DefaultServerURL(v.(string), "", apimachineryschema.GroupVersion{}, defaultTLS)
+		if err != nil {
+			return nil, err
+		}
+
+		overrides.ClusterInfo.Server = host.String()
+	}
+	if v, ok := d.GetOk("username"); ok {
+		overrides.AuthInfo.Username = v.(string)
+	}
+	if v, ok := d.GetOk("password"); ok {
+		overrides.AuthInfo.Password = v.(string)
+	}
+	if v, ok := d.GetOk("client_key"); ok {
+		overrides.AuthInfo.ClientKeyData = bytes.NewBufferString(v.(string)).Bytes()
+	}
+	if v, ok := d.GetOk("token"); ok {
+		overrides.AuthInfo.Token = v.(string)
+	}
+
+	if v, ok := d.GetOk("exec"); ok {
+		exec := &clientcmdapi.ExecConfig{}
+		if spec, ok := v.([]interface{})[0].(map[string]interface{}); ok {
+			exec.APIVersion = spec["api_version"].(string)
+			exec.Command = spec["command"].(string)
+			exec.Args = expandStringSlice(spec["args"].([]interface{}))
+			for kk, vv := range spec["env"].(map[string]interface{}) {
+				exec.Env = append(exec.Env, clientcmdapi.ExecEnvVar{Name: kk, Value: vv.(string)})
+			}
+		} else {
+			return nil, fmt.Errorf("failed to parse exec")
+		}
+		overrides.AuthInfo.Exec = exec
+	}
+
+	cc := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loader, overrides)
+	cfg, err := cc.ClientConfig()
+	if err != nil {
+		log.Printf("[WARN] Invalid provider configuration was supplied. Provider operations likely to fail: %v", err)
+		return nil, nil
+	}
+
+	return cfg, nil
+}
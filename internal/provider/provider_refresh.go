@@ -0,0 +1,144 @@
+package provider
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Values of the provider settings choosing what Read does when an object cannot be read
+// normally.
+const (
+	refreshFail   = "fail"
+	refreshKeep   = "keep"
+	refreshRemove = "remove"
+)
+
+var refreshBehaviors = []string{refreshFail, refreshKeep, refreshRemove}
+
+// refreshBehaviorFields are the provider settings for the situations Read cannot handle
+// normally.
+func refreshBehaviorFields() map[string]*schema.Schema {
+	field := func(defaultValue, situation string) *schema.Schema {
+		return &schema.Schema{
+			Type:         schema.TypeString,
+			Optional:     true,
+			Default:      defaultValue,
+			ValidateFunc: validateAttributeValueIsIn(refreshBehaviors),
+			Description:  fmt.Sprintf("What refresh does when %s: `fail`, `keep` the state as-is with a warning, or `remove` the object from state as if it was deleted. Defaults to `%s`.", situation, defaultValue),
+		}
+	}
+	return map[string]*schema.Schema{
+		"unreachable_cluster_behavior":   field(refreshFail, "the cluster cannot be reached or the provider has no valid cluster configuration"),
+		"missing_crd_behavior":           field(refreshFail, "the custom resource definition of an object, such as BackendConfig, is not installed"),
+		"terminating_namespace_behavior": field(refreshKeep, "the namespace of an object is being deleted"),
+	}
+}
+
+// refreshBehavior applies one of the refresh settings, falling back to defaultBehavior when
+// it is unset, and returns the diagnostic explaining what happened.
+func refreshBehavior(d *schema.ResourceData, behavior, defaultBehavior, summary, detail string) diag.Diagnostics {
+	if behavior == "" {
+		behavior = defaultBehavior
+	}
+	switch behavior {
+	case refreshKeep:
+		log.Printf("[WARN] %s, keeping %s in state", summary, d.Id())
+		return diag.Diagnostics{{
+			Severity: diag.Warning,
+			Summary:  summary,
+			Detail:   detail + " The state is kept as-is.",
+		}}
+	case refreshRemove:
+		log.Printf("[WARN] %s, removing %s from state", summary, d.Id())
+		d.SetId("")
+		return diag.Diagnostics{{
+			Severity: diag.Warning,
+			Summary:  summary,
+			Detail:   detail + " The object is removed from state.",
+		}}
+	default:
+		return diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  summary,
+			Detail:   detail,
+		}}
+	}
+}
+
+// refreshUnreachable handles a Read that failed to reach the cluster.
+func refreshUnreachable(d *schema.ResourceData, meta interface{}, err error) diag.Diagnostics {
+	return refreshBehavior(d, meta.(*apiClient).refreshUnreachableCluster, refreshFail,
+		"Kubernetes cluster unreachable",
+		fmt.Sprintf("Could not read %s: %s. Set unreachable_cluster_behavior in the provider to keep or remove objects of unreachable clusters.", d.Id(), err))
+}
+
+// refreshMissingCRD handles a Read of an object whose custom resource is not served.
+func refreshMissingCRD(d *schema.ResourceData, meta interface{}, gvr k8sschema.GroupVersionResource) diag.Diagnostics {
+	return refreshBehavior(d, meta.(*apiClient).refreshMissingCRD, refreshFail,
+		"Custom resource definition not installed",
+		fmt.Sprintf("Could not read %s: the cluster does not serve %s. Set missing_crd_behavior in the provider to keep or remove objects whose CRD was deleted.", d.Id(), gvr.GroupResource()))
+}
+
+// refreshTerminatingNamespace checks whether the namespace is being deleted, and if so
+// handles the Read, returning true when Read must stop.
+func refreshTerminatingNamespace(ctx context.Context, d *schema.ResourceData, meta interface{}, namespace string) (diag.Diagnostics, bool) {
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return nil, false
+	}
+	ns, err := conn.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if err != nil {
+		// Reading namespaces is often not allowed to tenants, the object is read anyway.
+		log.Printf("[DEBUG] Could not read namespace %s: %s", namespace, err)
+		return nil, false
+	}
+	if ns.Status.Phase != corev1.NamespaceTerminating {
+		return nil, false
+	}
+
+	diags := refreshBehavior(d, meta.(*apiClient).refreshTerminatingNamespace, refreshKeep,
+		"Namespace terminating",
+		fmt.Sprintf("The namespace %s of %s is being deleted.", namespace, d.Id()))
+	return diags, true
+}
+
+// isCRDMissing reports whether the cluster does not serve the resource, as opposed to only
+// the object being missing.
+func isCRDMissing(meta interface{}, gvr k8sschema.GroupVersionResource) bool {
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return false
+	}
+	resources, err := conn.Discovery().ServerResourcesForGroupVersion(gvr.GroupVersion().String())
+	if err != nil {
+		return errors.IsNotFound(err)
+	}
+	for _, r := range resources.APIResources {
+		if r.Name == gvr.Resource {
+			return false
+		}
+	}
+	return true
+}
+
+// isUnreachable reports whether the request failed before getting an answer from the API
+// server, or the API server could not serve it.
+func isUnreachable(err error) bool {
+	var urlErr *url.Error
+	var netErr net.Error
+	if stderrors.As(err, &urlErr) || stderrors.As(err, &netErr) {
+		return true
+	}
+	return errors.IsServiceUnavailable(err) || errors.IsServerTimeout(err)
+}
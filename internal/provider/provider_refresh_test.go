@@ -0,0 +1,93 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestBackendConfigReadRefreshBehavior(t *testing.T) {
+	terminating := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "leaving"},
+		Status:     corev1.NamespaceStatus{Phase: corev1.NamespaceTerminating},
+	}
+
+	cases := map[string]struct {
+		id       string
+		client   func() *apiClient
+		severity diag.Severity
+		removed  bool
+	}{
+		"unconfigured provider fails by default": {
+			id:       "default/web",
+			client:   func() *apiClient { return &apiClient{} },
+			severity: diag.Error,
+		},
+		"unconfigured provider removes": {
+			id:       "default/web",
+			client:   func() *apiClient { return &apiClient{refreshUnreachableCluster: refreshRemove} },
+			severity: diag.Warning,
+			removed:  true,
+		},
+		"missing CRD keeps": {
+			id: "default/web",
+			client: func() *apiClient {
+				clientset := fake.NewSimpleClientset()
+				clientset.Resources = []*metav1.APIResourceList{{
+					GroupVersion: backendConfigGVR.GroupVersion().String(),
+					APIResources: []metav1.APIResource{{Name: "frontendconfigs"}},
+				}}
+				return &apiClient{
+					clientset:         clientset,
+					dynamicClient:     dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()),
+					refreshMissingCRD: refreshKeep,
+				}
+			},
+			severity: diag.Warning,
+		},
+		"terminating namespace keeps by default": {
+			id: "leaving/web",
+			client: func() *apiClient {
+				return &apiClient{
+					clientset:     fake.NewSimpleClientset(terminating),
+					dynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()),
+				}
+			},
+			severity: diag.Warning,
+		},
+		"terminating namespace removes": {
+			id: "leaving/web",
+			client: func() *apiClient {
+				return &apiClient{
+					clientset:                   fake.NewSimpleClientset(terminating),
+					dynamicClient:               dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()),
+					refreshTerminatingNamespace: refreshRemove,
+				}
+			},
+			severity: diag.Warning,
+			removed:  true,
+		},
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			d := resourceBackendConfig().Data(&terraform.InstanceState{ID: tc.id})
+			diags := resourceBackendConfigRead(context.Background(), d, tc.client())
+
+			if len(diags) != 1 || diags[0].Severity != tc.severity {
+				t.Fatalf("expected one diagnostic of severity %v, got %#v", tc.severity, diags)
+			}
+			if removed := d.Id() == ""; removed != tc.removed {
+				t.Errorf("expected removed from state: %t, got %t", tc.removed, removed)
+			}
+		})
+	}
+}
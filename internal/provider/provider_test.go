@@ -1,9 +1,12 @@
 package provider
 
 import (
+	"context"
 	"testing"
 
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 )
 
 // providerFactories are used to instantiate a provider during acceptance testing.
@@ -26,3 +29,23 @@ func testAccPreCheck(t *testing.T) {
 	// about the appropriate environment variables being set are common to see in a pre-check
 	// function.
 }
+
+func TestProviderReadOnly(t *testing.T) {
+	ctx := context.Background()
+	p := New("dev")()
+	meta := &apiClient{readOnly: true}
+
+	for name, r := range p.ResourcesMap {
+		d := r.Data(&terraform.InstanceState{ID: "default/example"})
+		for action, f := range map[string]func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics{
+			"create": r.CreateContext,
+			"update": r.UpdateContext,
+			"delete": r.DeleteContext,
+		} {
+			diags := f(ctx, d, meta)
+			if !diags.HasError() || diags[0].Summary != "Provider is read-only" {
+				t.Errorf("%s: expected %s to fail, got %#v", name, action, diags)
+			}
+		}
+	}
+}
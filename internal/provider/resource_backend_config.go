@@ -1,33 +1,62 @@
 package provider
 
 import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
 	"time"
 
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apiValidation "k8s.io/apimachinery/pkg/api/validation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 )
 
+// forceDeleteTimeout bounds the removal of finalizers by force_delete, which happens once the
+// delete timeout has already elapsed.
+const forceDeleteTimeout = time.Minute
+
 func resourceBackendConfig() *schema.Resource {
 	return &schema.Resource{
-		//CreateContext: resourceBackendConfigCreate,
-		//ReadContext: resourceBackendConfigRead,
-		//UpdateContext: resourceBackendConfigUpdate,
-		//DeleteContext: resourceBackendConfigDelete,
+		CreateContext: resourceBackendConfigCreate,
+		ReadContext:   resourceBackendConfigRead,
+		UpdateContext: resourceBackendConfigUpdate,
+		DeleteContext: resourceBackendConfigDelete,
+		CustomizeDiff: resourceBackendConfigCustomizeDiff,
 		Importer: &schema.ResourceImporter{
-			StateContext: schema.ImportStatePassthroughContext,
+			StateContext: resourceBackendConfigImportState,
+		},
+		SchemaVersion: 2,
+		StateUpgraders: []schema.StateUpgrader{
+			{
+				Version: 0,
+				Type:    resourceBackendConfigV0().CoreConfigSchema().ImpliedType(),
+				Upgrade: resourceBackendConfigStateUpgradeV0,
+			},
+			{
+				Version: 1,
+				Type:    resourceBackendConfigV1().CoreConfigSchema().ImpliedType(),
+				Upgrade: resourceBackendConfigStateUpgradeV1,
+			},
 		},
-		SchemaVersion: 1,
 		Timeouts: &schema.ResourceTimeout{
 			Create: schema.DefaultTimeout(10 * time.Minute), //nolint:gomnd
 			Update: schema.DefaultTimeout(10 * time.Minute),
 			Delete: schema.DefaultTimeout(10 * time.Minute),
 		},
-		Schema: resourceBackendConfigSchemaV1(),
+		Schema: resourceBackendConfigSchemaV2(),
 	}
 }
 
 //nolint:funlen
-func resourceBackendConfigSchemaV1() map[string]*schema.Schema {
-	return map[string]*schema.Schema{
+func resourceBackendConfigSchemaV2() map[string]*schema.Schema {
+	s := map[string]*schema.Schema{
 		"metadata": namespacedMetadataSchema("backendconfig", false),
 		"spec": {
 			Type:        schema.TypeList,
@@ -37,10 +66,10 @@ func resourceBackendConfigSchemaV1() map[string]*schema.Schema {
 			Elem: &schema.Resource{
 				Schema: map[string]*schema.Schema{
 					"timeout_sec": {
-						Type:        schema.TypeInt,
-						Description: "Set a backend service timeout period in seconds. If you do not specify a value, the default value is 30 seconds.",
-						Optional:    true,
-						Default:     30,
+						Type:         schema.TypeInt,
+						Description:  "Set a backend service timeout period in seconds. If you do not specify a value, the default value is 30 seconds.",
+						Optional:     true,
+						ValidateFunc: validatePositiveInteger,
 					},
 					"cdn": {
 						Type:        schema.TypeList,
@@ -80,21 +109,49 @@ func resourceBackendConfigSchemaV1() map[string]*schema.Schema {
 												Type:          schema.TypeSet,
 												Description:   "TODO: Specify a string array with the names of query string parameters to exclude from cache keys. All other parameters are included. You can specify query_string_blacklist or query_string_whitelist, but not both.",
 												Optional:      true,
-												ConflictsWith: []string{"query_string_whitelist"},
-												Elem:          &schema.Schema{Type: schema.TypeString, Required: true},
+												ConflictsWith: []string{"spec.0.cdn.0.cache_policy.0.query_string_whitelist"},
+												Elem:          &schema.Schema{Type: schema.TypeString},
 												Set:           schema.HashString,
 											},
 											"query_string_whitelist": {
 												Type:          schema.TypeSet,
 												Description:   "TODO: Specify a string array with the names of query string parameters to include in cache keys. All other parameters are excluded. You can query_string_blacklist or query_string_whitelist, but not both.",
 												Optional:      true,
-												ConflictsWith: []string{"query_string_blacklist"},
-												Elem:          &schema.Schema{Type: schema.TypeString, Required: true},
+												ConflictsWith: []string{"spec.0.cdn.0.cache_policy.0.query_string_blacklist"},
+												Elem:          &schema.Schema{Type: schema.TypeString},
 												Set:           schema.HashString,
 											},
 										},
 									},
 								},
+								"signed_url_cache_max_age_sec": {
+									Type:             schema.TypeString,
+									Description:      "Maximum number of seconds that responses to signed URL requests are considered fresh. After this time the response is revalidated before being served. If you omit this parameter, Google Cloud uses the default of 3600 seconds, `0` always revalidates.",
+									Optional:         true,
+									ValidateFunc:     validateTypeStringNullableInt,
+									DiffSuppressFunc: suppressEquivalentNumber,
+								},
+								"signed_url_keys": {
+									Type:        schema.TypeList,
+									Description: "Keys used to sign URLs for this backend. The key values are read from Kubernetes Secrets, see the `backend_config_signed_url_key` resource.",
+									Optional:    true,
+									Elem: &schema.Resource{
+										Schema: map[string]*schema.Schema{
+											"key_name": {
+												Type:         schema.TypeString,
+												Description:  "Name of the signed URL key. Must be unique within the backend and is the `KeyName` used when signing URLs.",
+												Required:     true,
+												ValidateFunc: validateSignedURLKeyName,
+											},
+											"secret_name": {
+												Type:         schema.TypeString,
+												Description:  "Name of the Secret, in the namespace of the backendconfig, that holds the key value under the `key_value` key.",
+												Required:     true,
+												ValidateFunc: validateName,
+											},
+										},
+									},
+								},
 							},
 						},
 					},
@@ -120,29 +177,35 @@ func resourceBackendConfigSchemaV1() map[string]*schema.Schema {
 						Elem: &schema.Resource{
 							Schema: map[string]*schema.Schema{
 								"check_interval_sec": {
-									Type:        schema.TypeInt,
-									Description: "TODO: Specify the check-interval, in seconds, for each health check prober. This is the time from the start of one prober's check to the start of its next check. If you omit this parameter, the Google Cloud default of 5 seconds is used.",
-									Optional:    true,
+									Type:         schema.TypeInt,
+									Description:  "TODO: Specify the check-interval, in seconds, for each health check prober. This is the time from the start of one prober's check to the start of its next check. If you omit this parameter, the Google Cloud default of 5 seconds is used.",
+									Optional:     true,
+									ValidateFunc: validatePositiveInteger,
 								},
 								"timeout_sec": {
-									Type:        schema.TypeInt,
-									Description: "TODO: Specify the amount of time that Google Cloud waits for a response to a probe. The value of timeout must be less than or equal to the interval. Units are seconds. Each probe requires an HTTP 200 (OK) response code to be delivered before the probe timeout.",
-									Optional:    true,
+									Type:         schema.TypeInt,
+									Description:  "TODO: Specify the amount of time that Google Cloud waits for a response to a probe. The value of timeout must be less than or equal to the interval. Units are seconds. Each probe requires an HTTP 200 (OK) response code to be delivered before the probe timeout.",
+									Optional:     true,
+									ValidateFunc: validatePositiveInteger,
 								},
 								"healthy_threshold": {
-									Type:        schema.TypeInt,
-									Description: "TODO: Specify the number of sequential connection attempts that must succeed or fail, for at least one prober, in order to change the health state from healthy to unhealthy or vice versa. If you omit one of these parameters, Google Cloud uses the default value of 2.",
-									Optional:    true,
+									Type:         schema.TypeInt,
+									Description:  "TODO: Specify the number of sequential connection attempts that must succeed or fail, for at least one prober, in order to change the health state from healthy to unhealthy or vice versa. If you omit one of these parameters, Google Cloud uses the default value of 2.",
+									Optional:     true,
+									ValidateFunc: validatePositiveInteger,
 								},
 								"unhealthy_threshold": {
-									Type:        schema.TypeInt,
-									Description: "TODO: Specify the number of sequential connection attempts that must succeed or fail, for at least one prober, in order to change the health state from healthy to unhealthy or vice versa. If you omit one of these parameters, Google Cloud uses the default value of 2.",
-									Optional:    true,
+									Type:         schema.TypeInt,
+									Description:  "TODO: Specify the number of sequential connection attempts that must succeed or fail, for at least one prober, in order to change the health state from healthy to unhealthy or vice versa. If you omit one of these parameters, Google Cloud uses the default value of 2.",
+									Optional:     true,
+									ValidateFunc: validatePositiveInteger,
 								},
 								"type": {
-									Type:        schema.TypeString,
-									Description: "TODO: Specify a protocol used by probe systems for health checking. The BackendConfig only supports creating health checks using the HTTP, HTTPS, or HTTP2 protocols. For more information, see Success criteria for HTTP, HTTPS, and HTTP/2. You cannot omit this parameter.",
-									Required:    true,
+									Type:             schema.TypeString,
+									Description:      "TODO: Specify a protocol used by probe systems for health checking. The BackendConfig only supports creating health checks using the HTTP, HTTPS, or HTTP2 protocols. For more information, see Success criteria for HTTP, HTTPS, and HTTP/2. You cannot omit this parameter.",
+									Required:         true,
+									ValidateFunc:     validateCaseInsensitiveEnum(healthCheckTypes),
+									DiffSuppressFunc: suppressEquivalentCase,
 								},
 								"request_path": {
 									Type:        schema.TypeString,
@@ -150,9 +213,10 @@ func resourceBackendConfigSchemaV1() map[string]*schema.Schema {
 									Optional:    true,
 								},
 								"port": {
-									Type:        schema.TypeInt,
-									Description: "Specifies the port by using a port number. If you omit this parameter, Google Cloud uses the default of 80.",
-									Optional:    true,
+									Type:         schema.TypeInt,
+									Description:  "Specifies the port by using a port number. If you omit this parameter, Google Cloud uses the default of 80.",
+									Optional:     true,
+									ValidateFunc: validatePortNum,
 								},
 							},
 						},
@@ -183,9 +247,11 @@ func resourceBackendConfigSchemaV1() map[string]*schema.Schema {
 									Required:    true,
 								},
 								"sample_rate": {
-									Type:        schema.TypeFloat,
-									Description: "TODO: Specify a value from 0.0 through 1.0, where 0.0 means no packets are logged and 1.0 means 100% of packets are logged. This field is only relevant if enable is set to true. sampleRate is an optional field, but if it's configured then enable: true must also be set or else it is interpreted as enable: false",
-									Optional:    true,
+									Type:             schema.TypeString,
+									Description:      "TODO: Specify a value from 0.0 through 1.0, where 0.0 means no packets are logged and 1.0 means 100% of packets are logged. This field is only relevant if enable is set to true. sampleRate is an optional field, but if it's configured then enable: true must also be set or else it is interpreted as enable: false. If you omit this parameter, Google Cloud uses the default of 1.0.",
+									Optional:         true,
+									ValidateFunc:     validateTypeStringNullableFloat,
+									DiffSuppressFunc: suppressEquivalentNumber,
 								},
 							},
 						},
@@ -203,8 +269,23 @@ func resourceBackendConfigSchemaV1() map[string]*schema.Schema {
 								},
 								"oauthclient_credentials_secret_name": {
 									Type:         schema.TypeString,
-									RequiredWith: []string{"enabled"},
-									Description:  "TODO: To configure the BackendConfig for Identity-Aware Proxy IAP, you need to specify the enabled and secretName values to your iap block in your BackendConfig. To specify these values, ensure that you have the compute.backendServices.update permission.",
+									Optional:     true,
+									ValidateFunc: validateName,
+									Description:  "Name of the Secret holding the OAuth client under the `client_id` and `client_secret` keys. When `oauth_client_id` and `oauth_client_secret` are set the provider creates and owns this Secret, otherwise it must already exist. Omit it to use the Google-managed OAuth client.",
+								},
+								"oauth_client_id": {
+									Type:         schema.TypeString,
+									Optional:     true,
+									Sensitive:    true,
+									RequiredWith: []string{"spec.0.iap.0.oauthclient_credentials_secret_name", "spec.0.iap.0.oauth_client_secret"},
+									Description:  "OAuth client ID to store in the Secret named by `oauthclient_credentials_secret_name`.",
+								},
+								"oauth_client_secret": {
+									Type:         schema.TypeString,
+									Optional:     true,
+									Sensitive:    true,
+									RequiredWith: []string{"spec.0.iap.0.oauthclient_credentials_secret_name", "spec.0.iap.0.oauth_client_id"},
+									Description:  "OAuth client secret to store in the Secret named by `oauthclient_credentials_secret_name`.",
 								},
 							},
 						},
@@ -217,29 +298,81 @@ func resourceBackendConfigSchemaV1() map[string]*schema.Schema {
 						Elem: &schema.Resource{
 							Schema: map[string]*schema.Schema{
 								"affinity_type": {
-									Type:        schema.TypeString,
-									Description: "TODO: set affinityType to GENERATED_COOKIE or CLIENT_IP",
-									Required:    true,
+									Type:             schema.TypeString,
+									Description:      "TODO: set affinityType to GENERATED_COOKIE or CLIENT_IP",
+									Required:         true,
+									ValidateFunc:     validateCaseInsensitiveEnum(sessionAffinityTypes),
+									DiffSuppressFunc: suppressEquivalentCase,
 								},
 								"affinity_cookie_ttl_sec": {
-									Type:        schema.TypeInt,
-									Description: "TODO: To use a BackendConfig to set generated cookie affinity , set affinityType to GENERATED_COOKIE in your BackendConfig manifest. You can also use affinityCookieTtlSec to set the time period for the cookie to remain active.",
-									Optional:    true,
+									Type:             schema.TypeString,
+									Description:      "TODO: To use a BackendConfig to set generated cookie affinity , set affinityType to GENERATED_COOKIE in your BackendConfig manifest. You can also use affinityCookieTtlSec to set the time period for the cookie to remain active. `0` makes the cookie a session cookie.",
+									Optional:         true,
+									ValidateFunc:     validateTypeStringNullableNonNegativeInt,
+									DiffSuppressFunc: suppressEquivalentNumber,
 								},
 							},
 						},
 					},
 					"custom_request_headers": {
-						Type:     schema.TypeList,
-						Optional: true,
-						MaxItems: 1,
+						Type:        schema.TypeList,
+						Description: "Custom headers that the load balancer adds to requests forwarded to the backends. More info: https://cloud.google.com/kubernetes-engine/docs/how-to/ingress-features#request_headers",
+						Optional:    true,
+						MaxItems:    1,
 						Elem: &schema.Resource{
 							Schema: map[string]*schema.Schema{
 								"headers": {
-									Type:     schema.TypeSet,
-									Required: true,
-									Elem:     &schema.Schema{Type: schema.TypeString, Required: true},
-									Set:      schema.HashString,
+									Type:         schema.TypeSet,
+									Description:  "Headers in the form `Name:value`. Prefer `header`, which validates name and value separately.",
+									Optional:     true,
+									ExactlyOneOf: []string{"spec.0.custom_request_headers.0.headers", "spec.0.custom_request_headers.0.header"},
+									Elem: &schema.Schema{
+										Type:         schema.TypeString,
+										ValidateFunc: validateCustomHeader(customHeaderVariables),
+									},
+									Set: schema.HashString,
+								},
+								"header": {
+									Type:         schema.TypeList,
+									Description:  "A header to add to requests.",
+									Optional:     true,
+									ExactlyOneOf: []string{"spec.0.custom_request_headers.0.headers", "spec.0.custom_request_headers.0.header"},
+									Elem: &schema.Resource{
+										Schema: map[string]*schema.Schema{
+											"name": {
+												Type:         schema.TypeString,
+												Description:  "Name of the header. Must be a valid HTTP header name and not a hop-by-hop header.",
+												Required:     true,
+												ValidateFunc: validateHTTPHeaderName,
+											},
+											"value": {
+												Type:         schema.TypeString,
+												Description:  "Value of the header. May contain the variables supported by Google Cloud, such as `{client_region}` or `{tls_sni_hostname}`.",
+												Required:     true,
+												ValidateFunc: validateCustomHeaderValue(customHeaderVariables),
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+					"custom_response_headers": {
+						Type:        schema.TypeList,
+						Description: "Custom headers that the load balancer adds to responses, for example security headers such as `Strict-Transport-Security`. More info: https://cloud.google.com/kubernetes-engine/docs/how-to/ingress-features#response_headers",
+						Optional:    true,
+						MaxItems:    1,
+						Elem: &schema.Resource{
+							Schema: map[string]*schema.Schema{
+								"headers": {
+									Type:        schema.TypeSet,
+									Description: "Headers in the form `Name:value`. Values may contain the variables supported by Google Cloud, such as `{cdn_cache_status}`. Hop-by-hop headers cannot be set.",
+									Required:    true,
+									Elem: &schema.Schema{
+										Type:         schema.TypeString,
+										ValidateFunc: validateCustomHeader(customResponseHeaderVariables),
+									},
+									Set: schema.HashString,
 								},
 							},
 						},
@@ -247,5 +380,474 @@ func resourceBackendConfigSchemaV1() map[string]*schema.Schema {
 				},
 			},
 		},
+		"referenced_by": {
+			Type:        schema.TypeList,
+			Description: "The Service ports using this backendconfig through the `cloud.google.com/backend-config` or `beta.cloud.google.com/backend-config` annotation of Services in its namespace.",
+			Computed:    true,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"service": {
+						Type:        schema.TypeString,
+						Description: "Name of the Service.",
+						Computed:    true,
+					},
+					"port": {
+						Type:        schema.TypeString,
+						Description: "Port name or number the backendconfig is set for in `ports`, empty for the default of the Service.",
+						Computed:    true,
+					},
+					"default": {
+						Type:        schema.TypeBool,
+						Description: "Whether the backendconfig is the `default` of the Service, used by every port without a port-specific backendconfig.",
+						Computed:    true,
+					},
+				},
+			},
+		},
+		"deletion_protection": {
+			Type:        schema.TypeBool,
+			Description: "When true, destroying the backendconfig fails while a Service of its namespace references it through the `cloud.google.com/backend-config` or `beta.cloud.google.com/backend-config` annotation.",
+			Optional:    true,
+			Default:     false,
+		},
+		"force_delete": {
+			Type:        schema.TypeBool,
+			Description: "When the backendconfig is still not gone once the delete timeout has elapsed, remove its finalizers so that it is deleted. Otherwise the destroy fails, listing the finalizers blocking it.",
+			Optional:    true,
+			Default:     false,
+		},
+		"service_validation": {
+			Type:         schema.TypeString,
+			Description:  "How mismatches between the health check of the spec and the Services using the backendconfig are reported: a `health_check.port` that is not a targetPort nor a container port, a `health_check.type` that does not match the protocol of the port, or a Service without NEGs while the spec requires container-native load balancing. `warning` reports them on refresh and apply, `error` also fails plans changing the spec, `none` disables the checks.",
+			Optional:     true,
+			Default:      serviceValidationWarning,
+			ValidateFunc: validateAttributeValueIsIn([]string{serviceValidationError, serviceValidationWarning, serviceValidationNone}),
+		},
+	}
+	for k, v := range metadataManagementFields("backendconfig") {
+		s[k] = v
+	}
+	return s
+}
+
+func resourceBackendConfigCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if err := customizeDiffNamespace(d, meta); err != nil {
+		return err
+	}
+	if err := customizeDiffEffectiveMetadata(d, meta); err != nil {
+		return err
+	}
+	if err := validateIapOAuthSecret(ctx, d, meta); err != nil {
+		return err
+	}
+	if err := customizeDiffServiceValidation(ctx, d, meta); err != nil {
+		return err
+	}
+
+	spec := expandBackendConfigSpec(d.Get("spec").([]interface{}))
+	if spec.CustomRequestHeaders != nil {
+		if err := validateCustomHeaderLimits(spec.CustomRequestHeaders.Headers, "spec.0.custom_request_headers"); err != nil {
+			return err
+		}
+	}
+	if spec.CustomResponseHeaders != nil {
+		if err := validateCustomHeaderLimits(spec.CustomResponseHeaders.Headers, "spec.0.custom_response_headers"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func resourceBackendConfigCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	conn, err := meta.(*apiClient).DynamicClient()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	bc := backendConfig{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: backendConfigGVR.GroupVersion().String(),
+			Kind:       backendConfigKind,
+		},
+		ObjectMeta: expandEffectiveMetadata(d, meta),
+		Spec:       expandBackendConfigSpec(d.Get("spec").([]interface{})),
+	}
+	// Namespaces only known at apply time could not be checked when planning.
+	if err := meta.(*apiClient).checkNamespaceAllowed(bc.Namespace); err != nil {
+		return diag.Errorf("metadata.0.namespace: %s", err)
+	}
+	obj, err := backendConfigToUnstructured(&bc)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	log.Printf("[INFO] Creating new backend config: %#v", bc)
+	out, err := conn.Resource(backendConfigGVR).Namespace(bc.Namespace).Create(ctx, obj, metav1.CreateOptions{})
+	if err != nil {
+		return diag.Errorf("Failed to create backend config: %s", err)
+	}
+	log.Printf("[INFO] Submitted new backend config: %#v", out)
+
+	d.SetId(buildId(metav1.ObjectMeta{Namespace: out.GetNamespace(), Name: out.GetName()}))
+
+	if err := applyIapOAuthSecret(ctx, d, meta, out); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return resourceBackendConfigRead(ctx, d, meta)
+}
+
+func resourceBackendConfigRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	conn, err := meta.(*apiClient).DynamicClient()
+	if err != nil {
+		return refreshUnreachable(d, meta, err)
+	}
+
+	namespace, name, err := idParts(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if diags, stop := refreshTerminatingNamespace(ctx, d, meta, namespace); stop {
+		return diags
+	}
+
+	log.Printf("[INFO] Reading backend config %s", name)
+	out, err := conn.Resource(backendConfigGVR).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			if isCRDMissing(meta, backendConfigGVR) {
+				return refreshMissingCRD(d, meta, backendConfigGVR)
+			}
+			log.Printf("[WARN] Backend config %s not found, removing from state", d.Id())
+			d.SetId("")
+			return nil
+		}
+		if isUnreachable(err) {
+			return refreshUnreachable(d, meta, err)
+		}
+		log.Printf("[DEBUG] Received error: %#v", err)
+		return diag.FromErr(err)
+	}
+	log.Printf("[INFO] Received backend config: %#v", out)
+
+	bc, err := backendConfigFromUnstructured(out)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	diags := backendConfigNonCanonicalValueWarnings(bc.Spec)
+	diags = append(diags, backendConfigUnmodeledFieldWarnings(out)...)
+	diags = append(diags, backendConfigDriftWarnings(d, bc)...)
+	diags = append(diags, backendConfigServiceWarnings(ctx, d, meta, namespace, name, bc.Spec)...)
+
+	err = setEffectiveMetadata(d, meta, bc.ObjectMeta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	refs, err := listBackendConfigReferences(ctx, meta, namespace, name)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Could not list the Services referencing the backend config",
+			Detail:   fmt.Sprintf("referenced_by of backend config %s was not refreshed: %s", d.Id(), err),
+		})
+		// Keep the last known references, but never leave the attribute unknown.
+		refs = expandBackendConfigReferences(d.Get("referenced_by").([]interface{}))
+	}
+	if err := d.Set("referenced_by", flattenBackendConfigReferences(refs)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	// Stored explicitly so that imported resources do not plan a change to the defaults.
+	for _, k := range []string{"deletion_protection", "force_delete"} {
+		if err := d.Set(k, d.Get(k).(bool)); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	if d.Get("service_validation").(string) == "" {
+		if err := d.Set("service_validation", serviceValidationWarning); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	spec := flattenBackendConfigSpec(bc.Spec, d)
+	if err := refreshIapOAuthSecret(ctx, meta, bc.ObjectMeta, spec); err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Could not read the IAP OAuth client Secret",
+			Detail:   fmt.Sprintf("The OAuth client of backend config %s was not refreshed: %s", d.Id(), err),
+		})
+	}
+	err = d.Set("spec", spec)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	return diags
+}
+
+// backendConfigNonCanonicalValueWarnings warns about enum values on the cluster, for example
+// written by kubectl or an older provider, that the provider rewrites to their canonical case.
+func backendConfigNonCanonicalValueWarnings(spec backendConfigSpec) diag.Diagnostics {
+	var diags diag.Diagnostics
+	warn := func(attr, v string, valid []string) {
+		if c := canonicalEnumValue(v, valid); c != v {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("Non-canonical value for %s", attr),
+				Detail:   fmt.Sprintf("The backend config on the cluster has %s = %q, which is read as %q and will be written back as %q on the next update.", attr, v, c, c),
+			})
+		}
+	}
+
+	if spec.HealthCheck != nil && spec.HealthCheck.Type != nil {
+		warn("spec.health_check.type", *spec.HealthCheck.Type, healthCheckTypes)
+	}
+	if spec.SessionAffinity != nil {
+		warn("spec.session_affinity.affinity_type", spec.SessionAffinity.AffinityType, sessionAffinityTypes)
+	}
+
+	return diags
+}
+
+// backendConfigUnmodeledFieldWarnings warns about fields of the live spec the provider does
+// not know about, since they are not part of the plan and are lost on the next update.
+func backendConfigUnmodeledFieldWarnings(obj *unstructured.Unstructured) diag.Diagnostics {
+	fields := unmodeledFields(obj.Object["spec"], reflect.TypeOf(backendConfigSpec{}), "spec")
+	if len(fields) == 0 {
+		return nil
+	}
+	return diag.Diagnostics{{
+		Severity: diag.Warning,
+		Summary:  "Backend config has unmodeled fields",
+		Detail:   fmt.Sprintf("The backend config %s/%s on the cluster sets fields this provider does not support: %s. They are not shown in the plan and will be removed on the next update.", obj.GetNamespace(), obj.GetName(), strings.Join(fields, ", ")),
+	}}
+}
+
+// resourceBackendConfigImportState accepts an ID of the form namespace/name, optionally
+// prefixed with the API version, v1 or v1beta1, the object is read through. The ID stored
+// in state is always namespace/name.
+func resourceBackendConfigImportState(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	gvr, namespace, name, err := backendConfigImportIdParts(d.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := meta.(*apiClient).DynamicClient()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[INFO] Importing backend config %s/%s through %s", namespace, name, gvr.GroupVersion())
+	out, err := conn.Resource(gvr).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, fmt.Errorf("backend config %s/%s not found in %s", namespace, name, gvr.GroupVersion())
+		}
+		return nil, fmt.Errorf("failed to read backend config %s/%s: %s", namespace, name, err)
+	}
+
+	bc, err := backendConfigFromUnstructured(out)
+	if err != nil {
+		return nil, err
+	}
+
+	d.SetId(buildId(bc.ObjectMeta))
+	if err := setEffectiveMetadata(d, meta, bc.ObjectMeta); err != nil {
+		return nil, err
+	}
+	if err := d.Set("spec", flattenBackendConfigSpec(bc.Spec, d)); err != nil {
+		return nil, err
+	}
+
+	return []*schema.ResourceData{d}, nil
+}
+
+// backendConfigImportIdParts parses an import ID of the form [apiVersion/]namespace/name,
+// where apiVersion is v1 or v1beta1, with or without the cloud.google.com group.
+func backendConfigImportIdParts(id string) (k8sschema.GroupVersionResource, string, string, error) {
+	gvr := backendConfigGVR
+	parts := strings.Split(id, "/")
+	if len(parts) > 2 {
+		version := strings.Join(parts[:len(parts)-2], "/")
+		switch strings.TrimPrefix(version, backendConfigGVR.Group+"/") {
+		case backendConfigGVR.Version:
+		case backendConfigV1beta1GVR.Version:
+			gvr = backendConfigV1beta1GVR
+		default:
+			return gvr, "", "", fmt.Errorf("unexpected API version %q in import ID %q, expected %q or %q", version, id, backendConfigGVR.Version, backendConfigV1beta1GVR.Version)
+		}
+		parts = parts[len(parts)-2:]
+	}
+	if len(parts) != 2 {
+		return gvr, "", "", fmt.Errorf("unexpected import ID format (%q), expected %q or %q", id, "namespace/name", "apiVersion/namespace/name")
+	}
+
+	namespace, name := parts[0], parts[1]
+	var es []string
+	for _, msg := range apiValidation.ValidateNamespaceName(namespace, false) {
+		es = append(es, fmt.Sprintf("namespace %q %s", namespace, msg))
+	}
+	for _, msg := range apiValidation.NameIsDNSSubdomain(name, false) {
+		es = append(es, fmt.Sprintf("name %q %s", name, msg))
+	}
+	if len(es) > 0 {
+		return gvr, "", "", fmt.Errorf("invalid import ID %q: %s", id, strings.Join(es, ", "))
+	}
+
+	return gvr, namespace, name, nil
+}
+
+func resourceBackendConfigUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	conn, err := meta.(*apiClient).DynamicClient()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	namespace, name, err := idParts(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	live, err := conn.Resource(backendConfigGVR).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	bc, err := backendConfigFromUnstructured(live)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if d.HasChanges("metadata.0.annotations", "effective_annotations") {
+		o, n := effectiveMetadataChange(d, meta, "annotations")
+		bc.Annotations = patchStringMap(bc.Annotations, o, n)
+	}
+	if d.HasChanges("metadata.0.labels", "effective_labels") {
+		o, n := effectiveMetadataChange(d, meta, "labels")
+		bc.Labels = patchStringMap(bc.Labels, o, n)
+	}
+	bc.Spec = expandBackendConfigSpec(d.Get("spec").([]interface{}))
+
+	obj, err := backendConfigToUnstructured(bc)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	log.Printf("[INFO] Updating backend config %q: %#v", name, bc)
+	out, err := conn.Resource(backendConfigGVR).Namespace(namespace).Update(ctx, obj, metav1.UpdateOptions{})
+	if err != nil {
+		return diag.Errorf("Failed to update backend config: %s", err)
+	}
+	log.Printf("[INFO] Submitted updated backend config: %#v", out)
+
+	if d.HasChange("spec.0.iap") {
+		if err := applyIapOAuthSecret(ctx, d, meta, out); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	return resourceBackendConfigRead(ctx, d, meta)
+}
+
+func resourceBackendConfigDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	conn, err := meta.(*apiClient).DynamicClient()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	namespace, name, err := idParts(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if d.Get("deletion_protection").(bool) {
+		refs, err := listBackendConfigReferences(ctx, meta, namespace, name)
+		if err != nil {
+			return diag.Errorf("Failed to check the deletion protection of backend config %s: %s", d.Id(), err)
+		}
+		if len(refs) > 0 {
+			return diag.Diagnostics{{
+				Severity: diag.Error,
+				Summary:  "Backend config is protected from deletion",
+				Detail:   fmt.Sprintf("Backend config %s has deletion_protection set and is still referenced by: %s. Remove these references, or set deletion_protection to false, before destroying it.", d.Id(), formatBackendConfigReferences(refs)),
+			}}
+		}
+	}
+
+	ri := conn.Resource(backendConfigGVR).Namespace(namespace)
+	log.Printf("[INFO] Deleting backend config: %#v", name)
+	err = ri.Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return diag.Errorf("Failed to delete backend config: %s", err)
+	}
+
+	// Wait for the object to be gone, so that a replacement with the same name can be created.
+	err = waitForDeletion(ctx, ri, name)
+	if err != nil && ctx.Err() == nil {
+		return diag.Errorf("Failed to wait for backend config %s to be deleted: %s", d.Id(), err)
+	}
+	if err != nil {
+		finalizers := []string{}
+		if live, err := ri.Get(context.Background(), name, metav1.GetOptions{}); err == nil {
+			finalizers = live.GetFinalizers()
+		}
+		if !d.Get("force_delete").(bool) {
+			return diag.Errorf("Backend config %s was not deleted within %s, it is held by the finalizers %q. Set force_delete to remove them.", d.Id(), d.Timeout(schema.TimeoutDelete), finalizers)
+		}
+
+		// The delete timeout has elapsed, so the finalizers are removed with a context of its own.
+		forceCtx, cancel := context.WithTimeout(context.Background(), forceDeleteTimeout)
+		defer cancel()
+		log.Printf("[WARN] Removing finalizers %q of backend config %s", finalizers, d.Id())
+		_, err = ri.Patch(forceCtx, name, types.MergePatchType, []byte(`{"metadata":{"finalizers":null}}`), metav1.PatchOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return diag.Errorf("Failed to remove the finalizers of backend config %s: %s", d.Id(), err)
+		}
+		if err := waitForDeletion(forceCtx, ri, name); err != nil {
+			return diag.Errorf("Backend config %s was not deleted after removing its finalizers: %s", d.Id(), err)
+		}
+	}
+	log.Printf("[INFO] Backend config %s deleted", name)
+
+	d.SetId("")
+	return nil
+}
+
+// listBackendConfigReferences returns the ports of the Services in the namespace that use
+// the named backendconfig.
+func listBackendConfigReferences(ctx context.Context, meta interface{}, namespace, name string) ([]backendConfigReference, error) {
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return nil, err
+	}
+	services, err := conn.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []backendConfigReference
+	for _, svc := range services.Items {
+		r, err := backendConfigReferences(svc, name)
+		if err != nil {
+			log.Printf("[WARN] Ignoring the invalid backend config annotation of service %s/%s: %s", namespace, svc.Name, err)
+			continue
+		}
+		refs = append(refs, r...)
+	}
+	return refs, nil
+}
+
+func formatBackendConfigReferences(refs []backendConfigReference) string {
+	out := make([]string, 0, len(refs))
+	for _, r := range refs {
+		if r.Default {
+			out = append(out, fmt.Sprintf("service %s (default)", r.Service))
+		} else {
+			out = append(out, fmt.Sprintf("service %s (port %s)", r.Service, r.Port))
+		}
 	}
+	return strings.Join(out, ", ")
 }
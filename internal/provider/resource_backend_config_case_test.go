@@ -0,0 +1,81 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestBackendConfigEnumCase reads a backendconfig written with lower case enum values, then
+// plans configurations using either case: neither plans a change, and the read warns about
+// the values that will be rewritten.
+func TestBackendConfigEnumCase(t *testing.T) {
+	ctx := context.Background()
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cloud.google.com/v1",
+		"kind":       backendConfigKind,
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "default"},
+		"spec": map[string]interface{}{
+			"healthCheck":     map[string]interface{}{"type": "http"},
+			"sessionAffinity": map[string]interface{}{"affinityType": "generated_cookie"},
+		},
+	}}
+	meta := &apiClient{
+		namespace:     "default",
+		clientset:     fake.NewSimpleClientset(),
+		dynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), obj),
+	}
+
+	r := resourceBackendConfig()
+	d := r.Data(&terraform.InstanceState{ID: "default/web"})
+	diags := resourceBackendConfigRead(ctx, d, meta)
+	if diags.HasError() {
+		t.Fatalf("unexpected error: %#v", diags)
+	}
+	for _, attr := range []string{"spec.health_check.type", "spec.session_affinity.affinity_type"} {
+		if !hasWarning(diags, "Non-canonical value for "+attr) {
+			t.Errorf("expected a warning for %s, got %#v", attr, diags)
+		}
+	}
+	if v := d.Get("spec.0.health_check.0.type"); v != "HTTP" {
+		t.Errorf("expected the canonical value in state, got %q", v)
+	}
+
+	for _, healthCheckType := range []string{"http", "HTTP"} {
+		config := terraform.NewResourceConfigRaw(map[string]interface{}{
+			"metadata": []interface{}{map[string]interface{}{"name": "web", "namespace": "default"}},
+			"spec": []interface{}{map[string]interface{}{
+				"health_check":     []interface{}{map[string]interface{}{"type": healthCheckType}},
+				"session_affinity": []interface{}{map[string]interface{}{"affinity_type": "GENERATED_COOKIE"}},
+			}},
+		})
+		diff, err := r.Diff(ctx, d.State(), config, meta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff == nil {
+			continue
+		}
+		for k, attr := range diff.Attributes {
+			if strings.HasPrefix(k, "spec.") {
+				t.Errorf("%s: unexpected diff of %s: %q to %q", healthCheckType, k, attr.Old, attr.New)
+			}
+		}
+	}
+}
+
+func hasWarning(diags diag.Diagnostics, summary string) bool {
+	for _, d := range diags {
+		if d.Severity == diag.Warning && d.Summary == summary {
+			return true
+		}
+	}
+	return false
+}
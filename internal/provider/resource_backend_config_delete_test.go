@@ -0,0 +1,166 @@
+package provider
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestBackendConfigDeleteWaitsForRemoval(t *testing.T) {
+	cases := map[string]struct {
+		stuck       bool
+		forceDelete bool
+		errContains string
+	}{
+		"deleted":                 {},
+		"stuck on finalizers":     {stuck: true, errContains: `"example.com/cleanup"`},
+		"stuck with force_delete": {stuck: true, forceDelete: true},
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			obj := &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "cloud.google.com/v1",
+				"kind":       backendConfigKind,
+				"metadata": map[string]interface{}{
+					"name":       "web",
+					"namespace":  "default",
+					"finalizers": []interface{}{"example.com/cleanup"},
+				},
+			}}
+			client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), obj)
+
+			patched := false
+			if tc.stuck {
+				// The API server only marks the object for deletion while it has finalizers.
+				client.PrependReactor("delete", "backendconfigs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, nil
+				})
+				client.PrependReactor("patch", "backendconfigs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					patched = true
+					return true, obj, nil
+				})
+				client.PrependReactor("get", "backendconfigs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					if patched {
+						return true, nil, errors.NewNotFound(backendConfigGVR.GroupResource(), "web")
+					}
+					return false, nil, nil
+				})
+			}
+
+			r := resourceBackendConfig()
+			d := r.Data(&terraform.InstanceState{ID: "default/web"})
+			if err := d.Set("force_delete", tc.forceDelete); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			diags := resourceBackendConfigDelete(ctx, d, &apiClient{dynamicClient: client})
+
+			if tc.errContains == "" {
+				if diags.HasError() {
+					t.Fatalf("unexpected error: %#v", diags)
+				}
+				if tc.stuck && !patched {
+					t.Error("expected the finalizers to be removed")
+				}
+				return
+			}
+			if !diags.HasError() || !strings.Contains(diags[0].Summary, tc.errContains) {
+				t.Fatalf("expected an error mentioning %s, got %#v", tc.errContains, diags)
+			}
+			if patched {
+				t.Error("finalizers removed without force_delete")
+			}
+		})
+	}
+}
+
+// backendConfigReferencingServices returns Services of which api and legacy reference the
+// backend config default/web.
+func backendConfigReferencingServices() []runtime.Object {
+	return []runtime.Object{
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default", Annotations: map[string]string{
+			backendConfigAnnotation: `{"default": "web"}`,
+		}}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "legacy", Namespace: "default", Annotations: map[string]string{
+			betaBackendConfigAnnotation: `{"ports": {"http": "web", "8443": "other"}}`,
+		}}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "default", Annotations: map[string]string{
+			backendConfigAnnotation: `{"default": "other"}`,
+		}}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "elsewhere", Namespace: "other", Annotations: map[string]string{
+			backendConfigAnnotation: `{"default": "web"}`,
+		}}},
+	}
+}
+
+func TestBackendConfigDeletionProtection(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cloud.google.com/v1",
+		"kind":       backendConfigKind,
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "default"},
+	}}
+	meta := &apiClient{
+		clientset:     fake.NewSimpleClientset(backendConfigReferencingServices()...),
+		dynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), obj),
+	}
+
+	d := resourceBackendConfig().Data(&terraform.InstanceState{ID: "default/web"})
+	if err := d.Set("deletion_protection", true); err != nil {
+		t.Fatal(err)
+	}
+	diags := resourceBackendConfigDelete(context.Background(), d, meta)
+	if !diags.HasError() {
+		t.Fatal("expected the delete to be refused")
+	}
+	expected := "service api (default), service legacy (port http)"
+	if !strings.Contains(diags[0].Detail, expected) {
+		t.Errorf("expected %q in %q", expected, diags[0].Detail)
+	}
+
+	if err := d.Set("deletion_protection", false); err != nil {
+		t.Fatal(err)
+	}
+	if diags := resourceBackendConfigDelete(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected error: %#v", diags)
+	}
+}
+
+func TestBackendConfigReferencedBy(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cloud.google.com/v1",
+		"kind":       backendConfigKind,
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "default"},
+	}}
+	meta := &apiClient{
+		clientset:     fake.NewSimpleClientset(backendConfigReferencingServices()...),
+		dynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), obj),
+	}
+
+	d := resourceBackendConfig().Data(&terraform.InstanceState{ID: "default/web"})
+	if diags := resourceBackendConfigRead(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected error: %#v", diags)
+	}
+	expected := []interface{}{
+		map[string]interface{}{"service": "api", "port": "", "default": true},
+		map[string]interface{}{"service": "legacy", "port": "http", "default": false},
+	}
+	if actual := d.Get("referenced_by"); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
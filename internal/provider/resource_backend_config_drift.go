@@ -0,0 +1,183 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// backendConfigDriftWarnings compares the spec recorded in state with the one read from the
+// cluster and warns about every field that changed, naming the field managers that last
+// wrote it according to managedFields.
+func backendConfigDriftWarnings(d *schema.ResourceData, live *backendConfig) diag.Diagnostics {
+	if d.IsNewResource() || len(d.Get("spec").([]interface{})) == 0 {
+		return nil
+	}
+
+	// Both sides go through expand so that only differences Terraform would plan are reported.
+	recorded, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&backendConfig{
+		Spec: expandBackendConfigSpec(d.Get("spec").([]interface{})),
+	})
+	if err != nil {
+		return nil
+	}
+	current, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&backendConfig{
+		Spec: expandBackendConfigSpec(flattenBackendConfigSpec(live.Spec, d)),
+	})
+	if err != nil {
+		return nil
+	}
+
+	var diags diag.Diagnostics
+	for _, path := range driftedFields(recorded["spec"], current["spec"], []string{"spec"}) {
+		o, n := fieldValue(recorded, path), fieldValue(current, path)
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  fmt.Sprintf("Backend config %s changed outside of Terraform", strings.Join(path, ".")),
+			Detail: fmt.Sprintf("%s of backend config %s/%s changed from %s to %s. %s",
+				strings.Join(path, "."), live.Namespace, live.Name, formatFieldValue(o), formatFieldValue(n),
+				describeFieldManagers(live.ManagedFields, path, n == nil)),
+		})
+	}
+	return diags
+}
+
+// driftedFields returns the paths of the leaves that differ between o and n. Lists are
+// compared as a whole.
+func driftedFields(o, n interface{}, path []string) [][]string {
+	om, oIsMap := o.(map[string]interface{})
+	nm, nIsMap := n.(map[string]interface{})
+	if !oIsMap || !nIsMap {
+		if reflect.DeepEqual(o, n) {
+			return nil
+		}
+		return [][]string{path}
+	}
+
+	keys := make(map[string]bool)
+	for k := range om {
+		keys[k] = true
+	}
+	for k := range nm {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var out [][]string
+	for _, k := range sorted {
+		p := append(append([]string{}, path...), k)
+		out = append(out, driftedFields(om[k], nm[k], p)...)
+	}
+	return out
+}
+
+func fieldValue(obj map[string]interface{}, path []string) interface{} {
+	var v interface{} = obj
+	for _, k := range path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[k]
+	}
+	return v
+}
+
+func formatFieldValue(v interface{}) string {
+	if v == nil {
+		return "unset"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
+
+// describeFieldManagers names the managers owning the field at path, most recent first. A
+// removed field has no owner left, so the managers of its closest owned parent are named.
+func describeFieldManagers(entries []metav1.ManagedFieldsEntry, path []string, removed bool) string {
+	for p := path; len(p) > 0; p = p[:len(p)-1] {
+		owners := fieldManagers(entries, p)
+		if len(owners) == 0 {
+			continue
+		}
+		names := make([]string, 0, len(owners))
+		for _, e := range owners {
+			s := fmt.Sprintf("%q (%s)", e.Manager, e.Operation)
+			if e.Time != nil {
+				s += " at " + e.Time.UTC().Format(time.RFC3339)
+			}
+			names = append(names, s)
+		}
+		if len(p) == len(path) && !removed {
+			return fmt.Sprintf("Last changed by %s.", strings.Join(names, ", "))
+		}
+		return fmt.Sprintf("%s is managed by %s.", strings.Join(p, "."), strings.Join(names, ", "))
+	}
+	return "No field manager owns this field."
+}
+
+// fieldManagers returns the managedFields entries owning the field at path, ordered from
+// the most recent.
+func fieldManagers(entries []metav1.ManagedFieldsEntry, path []string) []metav1.ManagedFieldsEntry {
+	var out []metav1.ManagedFieldsEntry
+	for _, e := range entries {
+		if e.FieldsV1 == nil {
+			continue
+		}
+		fields := make(map[string]interface{})
+		if err := json.Unmarshal(e.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+		var v interface{} = fields
+		for _, k := range path {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				v = nil
+				break
+			}
+			v = m["f:"+k]
+		}
+		if ownsField(v) {
+			out = append(out, e)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Time == nil || out[j].Time == nil {
+			return out[j].Time == nil && out[i].Time != nil
+		}
+		return out[j].Time.Before(out[i].Time)
+	})
+	return out
+}
+
+// ownsField reports whether a node of a fieldsV1 set marks the field itself as owned, rather
+// than only leading to owned children.
+func ownsField(node interface{}) bool {
+	m, ok := node.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	if _, ok := m["."]; ok {
+		return true
+	}
+	for k := range m {
+		if strings.HasPrefix(k, "f:") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBackendConfigDriftWarnings(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourceBackendConfig().Schema, map[string]interface{}{
+		"metadata": []interface{}{map[string]interface{}{"name": "web", "namespace": "default"}},
+		"spec": []interface{}{map[string]interface{}{
+			"timeout_sec": 30,
+			"health_check": []interface{}{map[string]interface{}{
+				"type":         "HTTP",
+				"request_path": "/healthz",
+			}},
+			"logging": []interface{}{map[string]interface{}{"enable": true}},
+		}},
+	})
+
+	applied := metav1.NewTime(time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC))
+	edited := metav1.NewTime(time.Date(2021, 3, 2, 9, 30, 0, 0, time.UTC))
+	live := &backendConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "web",
+			Namespace: "default",
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				{
+					Manager:   "terraform-provider-febeconfig",
+					Operation: metav1.ManagedFieldsOperationUpdate,
+					Time:      &applied,
+					FieldsV1:  &metav1.FieldsV1{Raw: []byte(`{"f:spec":{".":{},"f:timeoutSec":{},"f:healthCheck":{".":{},"f:type":{}}}}`)},
+				},
+				{
+					Manager:   "kubectl-edit",
+					Operation: metav1.ManagedFieldsOperationUpdate,
+					Time:      &edited,
+					FieldsV1:  &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:healthCheck":{"f:requestPath":{}}}}`)},
+				},
+			},
+		},
+		Spec: backendConfigSpec{
+			TimeoutSec: ptrToInt64(30),
+			HealthCheck: &healthCheckConfig{
+				Type:        ptrToString("http"),
+				RequestPath: ptrToString("/ready"),
+			},
+		},
+	}
+
+	diags := backendConfigDriftWarnings(d, live)
+	if len(diags) != 2 {
+		t.Fatalf("expected 2 warnings, got %#v", diags)
+	}
+
+	if !strings.Contains(diags[0].Summary, "spec.healthCheck.requestPath") {
+		t.Errorf("unexpected summary: %s", diags[0].Summary)
+	}
+	for _, s := range []string{`"/healthz" to "/ready"`, `"kubectl-edit" (Update) at 2021-03-02T09:30:00Z`} {
+		if !strings.Contains(diags[0].Detail, s) {
+			t.Errorf("expected %q in %q", s, diags[0].Detail)
+		}
+	}
+
+	if !strings.Contains(diags[1].Summary, "spec.logging") {
+		t.Errorf("unexpected summary: %s", diags[1].Summary)
+	}
+	for _, s := range []string{"to unset", `spec is managed by "terraform-provider-febeconfig" (Update)`} {
+		if !strings.Contains(diags[1].Detail, s) {
+			t.Errorf("expected %q in %q", s, diags[1].Detail)
+		}
+	}
+}
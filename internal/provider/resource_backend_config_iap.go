@@ -0,0 +1,257 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Keys the GKE ingress controller reads from the IAP OAuth client Secret.
+const (
+	iapOAuthClientIDKey     = "client_id"
+	iapOAuthClientSecretKey = "client_secret"
+)
+
+// validateIapOAuthSecret fails the plan when the backendconfig references an existing
+// Secret that is missing, or lacks one of the keys the ingress controller expects.
+func validateIapOAuthSecret(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	for _, k := range []string{"spec.0.iap.0.oauthclient_credentials_secret_name", "spec.0.iap.0.oauth_client_id"} {
+		if !d.NewValueKnown(k) {
+			return nil
+		}
+	}
+	namespace, ok := plannedNamespace(d, meta)
+	if !ok {
+		return nil
+	}
+
+	name := d.Get("spec.0.iap.0.oauthclient_credentials_secret_name").(string)
+	if name == "" {
+		// The Google-managed OAuth client.
+		return nil
+	}
+
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return err
+	}
+
+	if d.Get("spec.0.iap.0.oauth_client_id").(string) != "" {
+		// A Secret created by this resource, which must not take over an existing one.
+		oldID, _ := d.GetChange("spec.0.iap.0.oauth_client_id")
+		if d.Id() != "" && oldID.(string) != "" && !d.HasChange("spec.0.iap.0.oauthclient_credentials_secret_name") {
+			return nil
+		}
+		secret, err := conn.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return nil
+			}
+			return fmt.Errorf("spec.0.iap.0.oauthclient_credentials_secret_name: failed to read Secret %s/%s: %s", namespace, name, err)
+		}
+		if !isOwnedByBackendConfig(secret.ObjectMeta, d.Get("metadata.0.name").(string)) {
+			return fmt.Errorf("spec.0.iap.0.oauthclient_credentials_secret_name: %s", errIapOAuthSecretExists(namespace, name))
+		}
+		return nil
+	}
+
+	secret, err := conn.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return fmt.Errorf("spec.0.iap.0.oauthclient_credentials_secret_name: Secret %s/%s does not exist", namespace, name)
+		}
+		return fmt.Errorf("spec.0.iap.0.oauthclient_credentials_secret_name: failed to read Secret %s/%s: %s", namespace, name, err)
+	}
+
+	var missing []string
+	for _, k := range []string{iapOAuthClientIDKey, iapOAuthClientSecretKey} {
+		if len(secret.Data[k]) == 0 {
+			missing = append(missing, k)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("spec.0.iap.0.oauthclient_credentials_secret_name: Secret %s/%s is missing the %s key(s)", namespace, name, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// applyIapOAuthSecret creates or updates the Secret holding the OAuth client given in
+// oauth_client_id and oauth_client_secret. The Secret is owned by the backendconfig, so it
+// is garbage collected with it, and it is released when the credentials are removed. It
+// carries the provider's default labels and annotations. An existing Secret the
+// backendconfig does not own is never taken over, since it would be garbage collected.
+func applyIapOAuthSecret(ctx context.Context, d *schema.ResourceData, meta interface{}, owner *unstructured.Unstructured) error {
+	oldName, newName := d.GetChange("spec.0.iap.0.oauthclient_credentials_secret_name")
+	oldID, newID := d.GetChange("spec.0.iap.0.oauth_client_id")
+	if oldID.(string) == "" && newID.(string) == "" {
+		return nil
+	}
+
+	client := meta.(*apiClient)
+	conn, err := client.MainClientset()
+	if err != nil {
+		return err
+	}
+	namespace := owner.GetNamespace()
+
+	if oldID.(string) != "" && (newID.(string) == "" || oldName.(string) != newName.(string)) {
+		// Keep a Secret that is still referenced by name, it just stops being managed.
+		orphan := oldName.(string) == newName.(string)
+		if err := releaseIapOAuthSecret(ctx, conn, namespace, oldName.(string), owner.GetUID(), orphan); err != nil {
+			return err
+		}
+	}
+	if newID.(string) == "" {
+		return nil
+	}
+
+	ownerRef := metav1.OwnerReference{
+		APIVersion: owner.GetAPIVersion(),
+		Kind:       owner.GetKind(),
+		Name:       owner.GetName(),
+		UID:        owner.GetUID(),
+	}
+	data := map[string][]byte{
+		iapOAuthClientIDKey:     []byte(newID.(string)),
+		iapOAuthClientSecretKey: []byte(d.Get("spec.0.iap.0.oauth_client_secret").(string)),
+	}
+
+	secret, err := conn.CoreV1().Secrets(namespace).Get(ctx, newName.(string), metav1.GetOptions{})
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            newName.(string),
+				Namespace:       namespace,
+				Labels:          client.defaultLabels,
+				Annotations:     client.defaultAnnotations,
+				OwnerReferences: []metav1.OwnerReference{ownerRef},
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: data,
+		}
+		log.Printf("[INFO] Creating IAP OAuth client secret %s/%s", namespace, secret.Name)
+		_, err = conn.CoreV1().Secrets(namespace).Create(ctx, secret, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to create IAP OAuth client secret: %s", err)
+		}
+		return nil
+	}
+
+	if !isOwnedBy(secret.ObjectMeta, owner.GetUID()) {
+		return errIapOAuthSecretExists(namespace, secret.Name)
+	}
+	secret.Labels = patchStringMap(secret.Labels, nil, mergeStringMaps(client.defaultLabels, nil))
+	secret.Annotations = patchStringMap(secret.Annotations, nil, mergeStringMaps(client.defaultAnnotations, nil))
+	secret.Data = data
+	log.Printf("[INFO] Updating IAP OAuth client secret %s/%s", namespace, secret.Name)
+	_, err = conn.CoreV1().Secrets(namespace).Update(ctx, secret, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to update IAP OAuth client secret: %s", err)
+	}
+	return nil
+}
+
+// refreshIapOAuthSecret replaces the OAuth client kept in a flattened spec by the one in the
+// Secret owned by the backendconfig, so that changes made to the Secret outside Terraform
+// are planned. A missing Secret, or one the backendconfig does not own, reads as no client.
+func refreshIapOAuthSecret(ctx context.Context, meta interface{}, owner metav1.ObjectMeta, spec []interface{}) error {
+	iap := flattenedBlock(flattenedBlock(spec)["iap"])
+	if iap == nil || iap["oauth_client_id"] == nil || iap["oauth_client_id"].(string) == "" {
+		return nil
+	}
+	name, _ := iap["oauthclient_credentials_secret_name"].(string)
+
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return err
+	}
+	secret, err := conn.CoreV1().Secrets(owner.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if err != nil || !isOwnedBy(secret.ObjectMeta, owner.UID) {
+		log.Printf("[WARN] IAP OAuth client secret %s/%s is no longer owned by backend config %s", owner.Namespace, name, owner.Name)
+		iap["oauth_client_id"] = ""
+		iap["oauth_client_secret"] = ""
+		return nil
+	}
+	iap["oauth_client_id"] = string(secret.Data[iapOAuthClientIDKey])
+	iap["oauth_client_secret"] = string(secret.Data[iapOAuthClientSecretKey])
+	return nil
+}
+
+// flattenedBlock returns the only element of a flattened block, nil when it is absent.
+func flattenedBlock(v interface{}) map[string]interface{} {
+	l, ok := v.([]interface{})
+	if !ok || len(l) == 0 {
+		return nil
+	}
+	m, _ := l[0].(map[string]interface{})
+	return m
+}
+
+func errIapOAuthSecretExists(namespace, name string) error {
+	return fmt.Errorf("Secret %s/%s already exists and is not owned by this backend config; remove oauth_client_id and oauth_client_secret to use it as is, or choose another name", namespace, name)
+}
+
+func releaseIapOAuthSecret(ctx context.Context, conn kubernetes.Interface, namespace, name string, uid types.UID, orphan bool) error {
+	secret, err := conn.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if !isOwnedBy(secret.ObjectMeta, uid) {
+		return nil
+	}
+
+	if !orphan {
+		log.Printf("[INFO] Deleting IAP OAuth client secret %s/%s", namespace, name)
+		return deleteSecretIfExists(ctx, conn, namespace, name)
+	}
+
+	refs := []metav1.OwnerReference{}
+	for _, ref := range secret.OwnerReferences {
+		if ref.UID != uid {
+			refs = append(refs, ref)
+		}
+	}
+	secret.OwnerReferences = refs
+	log.Printf("[INFO] Releasing IAP OAuth client secret %s/%s", namespace, name)
+	_, err = conn.CoreV1().Secrets(namespace).Update(ctx, secret, metav1.UpdateOptions{})
+	return err
+}
+
+// isOwnedByBackendConfig reports whether a backendconfig named name owns the object. It is
+// used when planning, before the UID of a new backendconfig is known.
+func isOwnedByBackendConfig(meta metav1.ObjectMeta, name string) bool {
+	for _, ref := range meta.OwnerReferences {
+		if ref.Kind == backendConfigKind && ref.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isOwnedBy(meta metav1.ObjectMeta, uid types.UID) bool {
+	for _, ref := range meta.OwnerReferences {
+		if ref.UID == uid {
+			return true
+		}
+	}
+	return false
+}
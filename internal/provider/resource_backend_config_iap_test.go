@@ -0,0 +1,224 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func testIapSecret(name string, data map[string]string, owners ...metav1.OwnerReference) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", OwnerReferences: owners},
+		Data:       map[string][]byte{},
+	}
+	for k, v := range data {
+		secret.Data[k] = []byte(v)
+	}
+	return secret
+}
+
+func testIapOwner() *unstructured.Unstructured {
+	owner := &unstructured.Unstructured{}
+	owner.SetAPIVersion("cloud.google.com/v1")
+	owner.SetKind(backendConfigKind)
+	owner.SetNamespace("default")
+	owner.SetName("web")
+	owner.SetUID("web-uid")
+	return owner
+}
+
+func testIapOwnerRef() metav1.OwnerReference {
+	return metav1.OwnerReference{APIVersion: "cloud.google.com/v1", Kind: backendConfigKind, Name: "web", UID: "web-uid"}
+}
+
+func TestValidateIapOAuthSecret(t *testing.T) {
+	client := map[string]string{iapOAuthClientIDKey: "id", iapOAuthClientSecretKey: "secret"}
+	meta := &apiClient{namespace: "default", clientset: fake.NewSimpleClientset(
+		testIapSecret("complete", client),
+		testIapSecret("partial", map[string]string{iapOAuthClientIDKey: "id"}),
+		testIapSecret("owned", client, testIapOwnerRef()),
+	)}
+
+	cases := map[string]struct {
+		iap         map[string]interface{}
+		errContains string
+	}{
+		"google-managed":     {iap: map[string]interface{}{"enabled": true}},
+		"existing secret":    {iap: map[string]interface{}{"enabled": true, "oauthclient_credentials_secret_name": "complete"}},
+		"missing secret":     {iap: map[string]interface{}{"enabled": true, "oauthclient_credentials_secret_name": "missing"}, errContains: "Secret default/missing does not exist"},
+		"missing key":        {iap: map[string]interface{}{"enabled": true, "oauthclient_credentials_secret_name": "partial"}, errContains: "missing the client_secret key(s)"},
+		"new managed secret": {iap: map[string]interface{}{"enabled": true, "oauthclient_credentials_secret_name": "missing", "oauth_client_id": "id", "oauth_client_secret": "secret"}},
+		"foreign secret":     {iap: map[string]interface{}{"enabled": true, "oauthclient_credentials_secret_name": "complete", "oauth_client_id": "id", "oauth_client_secret": "secret"}, errContains: "already exists and is not owned by this backend config"},
+		"owned secret":       {iap: map[string]interface{}{"enabled": true, "oauthclient_credentials_secret_name": "owned", "oauth_client_id": "id", "oauth_client_secret": "secret"}},
+	}
+
+	for name, tc := range cases {
+		config := terraform.NewResourceConfigRaw(map[string]interface{}{
+			"metadata": []interface{}{map[string]interface{}{"name": "web", "namespace": "default"}},
+			"spec":     []interface{}{map[string]interface{}{"iap": []interface{}{tc.iap}}},
+		})
+		_, err := resourceBackendConfig().Diff(context.Background(), nil, config, meta)
+		if tc.errContains == "" && err != nil {
+			t.Errorf("%s: unexpected error: %s", name, err)
+		}
+		if tc.errContains != "" && (err == nil || !strings.Contains(err.Error(), tc.errContains)) {
+			t.Errorf("%s: expected an error containing %q, got %v", name, tc.errContains, err)
+		}
+	}
+}
+
+func TestApplyIapOAuthSecret(t *testing.T) {
+	ctx := context.Background()
+	iapData := func(secretName string) *schema.ResourceData {
+		return schema.TestResourceDataRaw(t, resourceBackendConfig().Schema, map[string]interface{}{
+			"metadata": []interface{}{map[string]interface{}{"name": "web", "namespace": "default"}},
+			"spec": []interface{}{map[string]interface{}{"iap": []interface{}{map[string]interface{}{
+				"enabled":                             true,
+				"oauthclient_credentials_secret_name": secretName,
+				"oauth_client_id":                     "new-id",
+				"oauth_client_secret":                 "new-secret",
+			}}}},
+		})
+	}
+	meta := &apiClient{namespace: "default", clientset: fake.NewSimpleClientset(
+		testIapSecret("foreign", map[string]string{iapOAuthClientIDKey: "user-id", iapOAuthClientSecretKey: "user-secret"}),
+		testIapSecret("owned", map[string]string{iapOAuthClientIDKey: "old-id", iapOAuthClientSecretKey: "old-secret"}, testIapOwnerRef()),
+	)}
+	secrets := meta.clientset.CoreV1().Secrets("default")
+
+	if err := applyIapOAuthSecret(ctx, iapData("created"), meta, testIapOwner()); err != nil {
+		t.Fatal(err)
+	}
+	created, err := secrets.Get(ctx, "created", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(created.Data[iapOAuthClientIDKey]) != "new-id" || !isOwnedBy(created.ObjectMeta, "web-uid") {
+		t.Errorf("expected a Secret owned by the backend config, got %v", created)
+	}
+
+	if err := applyIapOAuthSecret(ctx, iapData("owned"), meta, testIapOwner()); err != nil {
+		t.Fatal(err)
+	}
+	owned, err := secrets.Get(ctx, "owned", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(owned.Data[iapOAuthClientSecretKey]) != "new-secret" {
+		t.Errorf("expected the owned Secret to be updated, got %v", owned.Data)
+	}
+
+	err = applyIapOAuthSecret(ctx, iapData("foreign"), meta, testIapOwner())
+	if err == nil || !strings.Contains(err.Error(), "already exists and is not owned") {
+		t.Errorf("expected the foreign Secret to be refused, got %v", err)
+	}
+	foreign, err := secrets.Get(ctx, "foreign", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(foreign.Data[iapOAuthClientIDKey]) != "user-id" || len(foreign.OwnerReferences) != 0 {
+		t.Errorf("expected the foreign Secret to be left alone, got %v", foreign)
+	}
+}
+
+func TestReleaseIapOAuthSecret(t *testing.T) {
+	ctx := context.Background()
+	other := metav1.OwnerReference{APIVersion: "v1", Kind: "ConfigMap", Name: "other", UID: "other-uid"}
+	meta := &apiClient{clientset: fake.NewSimpleClientset(
+		testIapSecret("deleted", nil, testIapOwnerRef()),
+		testIapSecret("orphaned", nil, testIapOwnerRef(), other),
+		testIapSecret("foreign", nil, other),
+	)}
+	conn := meta.clientset
+	secrets := conn.CoreV1().Secrets("default")
+
+	for _, tc := range []struct {
+		name   string
+		orphan bool
+	}{{"deleted", false}, {"orphaned", true}, {"foreign", false}, {"missing", false}} {
+		if err := releaseIapOAuthSecret(ctx, conn, "default", tc.name, "web-uid", tc.orphan); err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.name, err)
+		}
+	}
+
+	if _, err := secrets.Get(ctx, "deleted", metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("expected the owned Secret to be deleted, got %v", err)
+	}
+	orphaned, err := secrets.Get(ctx, "orphaned", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isOwnedBy(orphaned.ObjectMeta, "web-uid") || !isOwnedBy(orphaned.ObjectMeta, "other-uid") {
+		t.Errorf("expected only the backend config owner reference to be removed, got %v", orphaned.OwnerReferences)
+	}
+	if _, err := secrets.Get(ctx, "foreign", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the foreign Secret to be kept, got %v", err)
+	}
+}
+
+func TestRefreshIapOAuthSecret(t *testing.T) {
+	ctx := context.Background()
+	meta := &apiClient{clientset: fake.NewSimpleClientset(
+		testIapSecret("owned", map[string]string{iapOAuthClientIDKey: "edited-id", iapOAuthClientSecretKey: "edited-secret"}, testIapOwnerRef()),
+		testIapSecret("foreign", map[string]string{iapOAuthClientIDKey: "id", iapOAuthClientSecretKey: "secret"}),
+	)}
+	owner := metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "web-uid"}
+
+	cases := map[string]struct {
+		id, secret string
+	}{
+		"owned":   {"edited-id", "edited-secret"},
+		"foreign": {"", ""},
+		"missing": {"", ""},
+	}
+	for name, tc := range cases {
+		spec := []interface{}{map[string]interface{}{"iap": []interface{}{map[string]interface{}{
+			"enabled":                             true,
+			"oauthclient_credentials_secret_name": name,
+			"oauth_client_id":                     "id",
+			"oauth_client_secret":                 "secret",
+		}}}}
+		if err := refreshIapOAuthSecret(ctx, meta, owner, spec); err != nil {
+			t.Fatalf("%s: unexpected error: %s", name, err)
+		}
+		iap := flattenedBlock(flattenedBlock(spec)["iap"])
+		if iap["oauth_client_id"] != tc.id || iap["oauth_client_secret"] != tc.secret {
+			t.Errorf("%s: expected %q and %q, got %v", name, tc.id, tc.secret, iap)
+		}
+	}
+}
+
+// TestValidateIapOAuthSecretProviderNamespace plans backend configs without a namespace,
+// which are created in the provider namespace, through the same path Terraform uses.
+func TestValidateIapOAuthSecretProviderNamespace(t *testing.T) {
+	p := New("dev")()
+	p.SetMeta(&apiClient{namespace: "default", clientset: fake.NewSimpleClientset(
+		testIapSecret("complete", map[string]string{iapOAuthClientIDKey: "id", iapOAuthClientSecretKey: "secret"}),
+		testIapSecret("partial", map[string]string{iapOAuthClientIDKey: "id"}),
+	)})
+
+	cases := map[string]string{
+		"complete": "",
+		"partial":  "Secret default/partial is missing the client_secret key(s)",
+		"missing":  "Secret default/missing does not exist",
+	}
+
+	for secret, errContains := range cases {
+		config := testBackendConfigValue(t, `{"metadata": [{"name": "web"}], "spec": [{"iap": [{"enabled": true, "oauthclient_credentials_secret_name": "`+secret+`"}]}]}`)
+		errs := testPlanBackendConfig(t, p, config)
+		if errContains == "" && len(errs) > 0 {
+			t.Errorf("%s: unexpected errors: %q", secret, errs)
+		}
+		if errContains != "" && (len(errs) == 0 || !strings.Contains(errs[0], errContains)) {
+			t.Errorf("%s: expected an error containing %q, got %q", secret, errContains, errs)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestBackendConfigImportIdParts(t *testing.T) {
+	cases := map[string]struct {
+		version string
+		valid   bool
+	}{
+		"default/web":                          {"v1", true},
+		"v1/default/web":                       {"v1", true},
+		"v1beta1/default/web":                  {"v1beta1", true},
+		"cloud.google.com/v1beta1/default/web": {"v1beta1", true},
+		"web":                                  {"", false},
+		"v2/default/web":                       {"", false},
+		"networking.k8s.io/v1/default/web":     {"", false},
+		"Default/web":                          {"", false},
+		"default/web_1":                        {"", false},
+		"default/":                             {"", false},
+	}
+
+	for id, tc := range cases {
+		gvr, namespace, name, err := backendConfigImportIdParts(id)
+		if !tc.valid {
+			if err == nil {
+				t.Errorf("%s: expected an error", id)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", id, err)
+			continue
+		}
+		if gvr.Version != tc.version || namespace != "default" || name != "web" {
+			t.Errorf("%s: got %s %s/%s", id, gvr.Version, namespace, name)
+		}
+	}
+}
+
+func TestBackendConfigImportState(t *testing.T) {
+	ctx := context.Background()
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cloud.google.com/v1",
+		"kind":       backendConfigKind,
+		"metadata": map[string]interface{}{
+			"name":      "web",
+			"namespace": "default",
+			"labels":    map[string]interface{}{"app": "web"},
+		},
+		"spec": map[string]interface{}{
+			"timeoutSec": int64(40),
+			"healthCheck": map[string]interface{}{
+				"type":        "http",
+				"requestPath": "/healthz",
+				"port":        int64(8080),
+			},
+			"cdn": map[string]interface{}{
+				"enabled": true,
+				"bypassCacheOnRequestHeaders": []interface{}{
+					map[string]interface{}{"headerName": "X-Bypass"},
+				},
+			},
+			"sessionAffinity": map[string]interface{}{
+				"affinityType": "CLIENT_IP",
+			},
+		},
+	}}
+	meta := &apiClient{dynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), obj)}
+
+	r := resourceBackendConfig()
+	d := r.Data(&terraform.InstanceState{ID: "cloud.google.com/v1/default/web"})
+	imported, err := r.Importer.StateContext(ctx, d, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imported) != 1 {
+		t.Fatalf("expected one imported resource, got %d", len(imported))
+	}
+	d = imported[0]
+
+	expected := map[string]string{
+		"id":                                      "default/web",
+		"metadata.0.name":                         "web",
+		"metadata.0.namespace":                    "default",
+		"metadata.0.labels.app":                   "web",
+		"spec.0.timeout_sec":                      "40",
+		"spec.0.health_check.0.type":              "HTTP",
+		"spec.0.health_check.0.request_path":      "/healthz",
+		"spec.0.health_check.0.port":              "8080",
+		"spec.0.cdn.0.enabled":                    "true",
+		"spec.0.session_affinity.0.affinity_type": "CLIENT_IP",
+	}
+	attrs := d.State().Attributes
+	for k, v := range expected {
+		if attrs[k] != v {
+			t.Errorf("%s: expected %q, got %q", k, v, attrs[k])
+		}
+	}
+
+	diags := resourceBackendConfigRead(ctx, d, meta)
+	if diags.HasError() {
+		t.Fatalf("%#v", diags)
+	}
+	var warnings []string
+	for _, diag := range diags {
+		warnings = append(warnings, diag.Detail)
+	}
+	all := strings.Join(warnings, "\n")
+	for _, s := range []string{"spec.cdn.bypassCacheOnRequestHeaders", `"http"`} {
+		if !strings.Contains(all, s) {
+			t.Errorf("expected a warning mentioning %s, got %q", s, all)
+		}
+	}
+}
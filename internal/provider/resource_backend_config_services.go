@@ -0,0 +1,227 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Values of service_validation.
+const (
+	serviceValidationError   = "error"
+	serviceValidationWarning = "warning"
+	serviceValidationNone    = "none"
+)
+
+// customizeDiffServiceValidation fails the plan when service_validation is error and the
+// planned spec does not fit a Service referencing the backendconfig. Mismatches are only
+// looked for when the spec changes and the namespace is known, so that a Service modified
+// since does not block unrelated plans.
+func customizeDiffServiceValidation(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if d.Get("service_validation").(string) != serviceValidationError {
+		return nil
+	}
+	if d.Id() != "" && !d.HasChange("spec") && !d.HasChange("service_validation") {
+		return nil
+	}
+	if !d.NewValueKnown("metadata.0.name") || !d.NewValueKnown("spec.0.health_check") {
+		return nil
+	}
+	namespace, ok := plannedNamespace(d, meta)
+	if !ok {
+		return nil
+	}
+
+	name := d.Get("metadata.0.name").(string)
+	spec := expandBackendConfigSpec(d.Get("spec").([]interface{}))
+	mismatches, err := backendConfigServiceMismatches(ctx, meta, namespace, name, spec)
+	if err != nil {
+		log.Printf("[WARN] Could not check backend config %s/%s against its Services: %s", namespace, name, err)
+		return nil
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("backend config does not fit the Services using it:\n- %s", strings.Join(mismatches, "\n- "))
+	}
+	return nil
+}
+
+// backendConfigServiceWarnings reports the mismatches between spec and the Services
+// referencing the backendconfig, unless service_validation is none.
+func backendConfigServiceWarnings(ctx context.Context, d *schema.ResourceData, meta interface{}, namespace, name string, spec backendConfigSpec) diag.Diagnostics {
+	if d.Get("service_validation").(string) == serviceValidationNone {
+		return nil
+	}
+	mismatches, err := backendConfigServiceMismatches(ctx, meta, namespace, name, spec)
+	if err != nil {
+		log.Printf("[WARN] Could not check backend config %s/%s against its Services: %s", namespace, name, err)
+		return nil
+	}
+
+	var diags diag.Diagnostics
+	for _, m := range mismatches {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Backend config does not fit a Service using it",
+			Detail:   m + " The backends of the load balancer may be reported UNHEALTHY.",
+		})
+	}
+	return diags
+}
+
+// backendConfigServiceMismatches checks the health check of spec against each Service of
+// the namespace using the named backendconfig, for the ports using it:
+//   - health_check.port must be a targetPort of these ports or a container port of the Pods
+//     selected by the Service,
+//   - health_check.type must match the protocol of these ports, when known from their
+//     appProtocol or the app-protocols annotation of the Service,
+//   - the Service must be NEG-enabled when the spec uses a feature requiring
+//     container-native load balancing.
+func backendConfigServiceMismatches(ctx context.Context, meta interface{}, namespace, name string, spec backendConfigSpec) ([]string, error) {
+	hc := spec.HealthCheck
+	if hc == nil || (hc.Port == nil && hc.Type == nil) {
+		return nil, nil
+	}
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return nil, err
+	}
+	services, err := conn.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var mismatches []string
+	for _, svc := range services.Items {
+		configs, _, err := expandServiceBackendConfigs(svc.Annotations)
+		if err != nil {
+			continue
+		}
+		ports := servicePortsUsingBackendConfig(svc, configs, name)
+		if len(ports) == 0 {
+			continue
+		}
+
+		if hc.Port != nil {
+			port := int32(*hc.Port)
+			if !portsTarget(ports, port) && !podsExposePort(ctx, conn, svc, port) {
+				mismatches = append(mismatches, fmt.Sprintf("spec.health_check.port %d is neither a targetPort of service %s nor a container port of its Pods.", port, svc.Name))
+			}
+		}
+		for _, feature := range negRequiredFeatures(spec) {
+			if neg, err := serviceNEGAnnotation(svc); err == nil && !neg.Ingress {
+				mismatches = append(mismatches, fmt.Sprintf("%s requires container-native load balancing, but service %s has no %s annotation enabling ingress.", feature, svc.Name, negAnnotation))
+			}
+		}
+		if hc.Type != nil {
+			healthCheck := canonicalEnumValue(*hc.Type, healthCheckTypes)
+			protocols, _ := serviceAppProtocols(svc)
+			for _, p := range ports {
+				if protocol := servicePortProtocol(p, protocols); protocol != "" && protocol != healthCheck {
+					mismatches = append(mismatches, fmt.Sprintf("spec.health_check.type %s does not match the protocol %s of port %s of service %s.", healthCheck, protocol, servicePortKey(p), svc.Name))
+				}
+			}
+		}
+	}
+	return mismatches, nil
+}
+
+// negRequiredFeatures returns the settings of spec that only work with container-native
+// load balancing. A custom health check port is probed on the Pods directly, which the
+// instance groups of a Service without NEGs cannot do.
+func negRequiredFeatures(spec backendConfigSpec) []string {
+	var features []string
+	if spec.HealthCheck != nil && spec.HealthCheck.Port != nil {
+		features = append(features, "spec.health_check.port")
+	}
+	return features
+}
+
+// servicePortsUsingBackendConfig returns the ports of svc whose backendconfig is name,
+// either specifically or as the default of the Service.
+func servicePortsUsingBackendConfig(svc corev1.Service, configs serviceBackendConfigs, name string) []corev1.ServicePort {
+	var ports []corev1.ServicePort
+	for _, p := range svc.Spec.Ports {
+		bc, ok := configs.Ports[p.Name]
+		if !ok {
+			bc, ok = configs.Ports[strconv.Itoa(int(p.Port))]
+		}
+		if !ok {
+			bc = configs.Default
+		}
+		if bc == name {
+			ports = append(ports, p)
+		}
+	}
+	return ports
+}
+
+func portsTarget(ports []corev1.ServicePort, port int32) bool {
+	for _, p := range ports {
+		if p.TargetPort.Type == intstr.Int && p.TargetPort.IntVal == port {
+			return true
+		}
+		// Without targetPort, the Service targets its own port number.
+		if p.TargetPort.Type == intstr.Int && p.TargetPort.IntVal == 0 && p.Port == port {
+			return true
+		}
+	}
+	return false
+}
+
+// podsExposePort reports whether a Pod selected by svc has a container port numbered port.
+// It is true when no Pod can be found to tell.
+func podsExposePort(ctx context.Context, conn kubernetes.Interface, svc corev1.Service, port int32) bool {
+	if len(svc.Spec.Selector) == 0 {
+		return true
+	}
+	pods, err := conn.CoreV1().Pods(svc.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(svc.Spec.Selector).String(),
+	})
+	if err != nil || len(pods.Items) == 0 {
+		return true
+	}
+	for _, pod := range pods.Items {
+		for _, c := range pod.Spec.Containers {
+			for _, cp := range c.Ports {
+				if cp.ContainerPort == port {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// servicePortProtocol returns the protocol the load balancer uses for port p, from the
+// app-protocols annotation or the appProtocol of the port, empty when unknown.
+func servicePortProtocol(p corev1.ServicePort, protocols map[string]string) string {
+	if protocol, ok := protocols[p.Name]; ok {
+		return strings.ToUpper(protocol)
+	}
+	if p.AppProtocol != nil {
+		protocol := strings.ToUpper(*p.AppProtocol)
+		for _, v := range appProtocols {
+			if v == protocol {
+				return protocol
+			}
+		}
+	}
+	return ""
+}
+
+func servicePortKey(p corev1.ServicePort) string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return strconv.Itoa(int(p.Port))
+}
@@ -0,0 +1,141 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestBackendConfigServiceMismatches(t *testing.T) {
+	grpc := "HTTP2"
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Annotations: map[string]string{
+			backendConfigAnnotation: `{"default":"web","ports":{"admin":"admin"}}`,
+			appProtocolsAnnotation:  `{"https":"HTTPS"}`,
+			negAnnotation:           `{"ingress":true}`,
+		}},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app": "web"},
+			Ports: []corev1.ServicePort{
+				{Name: "https", Port: 443, TargetPort: intstr.FromInt(8443)},
+				{Name: "grpc", Port: 9000, TargetPort: intstr.FromString("grpc"), AppProtocol: &grpc},
+				{Name: "admin", Port: 9090, TargetPort: intstr.FromInt(9090)},
+			},
+		},
+	}
+	noNEG := svc.DeepCopy()
+	noNEG.Name = "legacy"
+	delete(noNEG.Annotations, negAnnotation)
+	delete(noNEG.Annotations, appProtocolsAnnotation)
+	noNEG.Spec.Ports = noNEG.Spec.Ports[:1]
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default", Labels: map[string]string{"app": "web"}},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name:  "web",
+			Ports: []corev1.ContainerPort{{Name: "grpc", ContainerPort: 9443}, {ContainerPort: 8080}},
+		}}},
+	}
+	meta := &apiClient{clientset: fake.NewSimpleClientset(svc, noNEG, pod)}
+
+	cases := map[string]struct {
+		healthCheck *healthCheckConfig
+		expected    []string
+	}{
+		"no health check": {},
+		"target port": {
+			healthCheck: &healthCheckConfig{Port: ptrToInt64(8443)},
+			expected:    []string{"spec.health_check.port requires container-native load balancing, but service legacy"},
+		},
+		"container port": {
+			healthCheck: &healthCheckConfig{Port: ptrToInt64(8080)},
+			expected:    []string{"spec.health_check.port requires container-native load balancing, but service legacy"},
+		},
+		"unknown port": {
+			healthCheck: &healthCheckConfig{Port: ptrToInt64(9090)},
+			expected: []string{
+				"spec.health_check.port 9090 is neither a targetPort of service legacy",
+				"spec.health_check.port requires container-native load balancing, but service legacy",
+				"spec.health_check.port 9090 is neither a targetPort of service web",
+			},
+		},
+		"protocol": {
+			healthCheck: &healthCheckConfig{Type: ptrToString("https")},
+			expected:    []string{"spec.health_check.type HTTPS does not match the protocol HTTP2 of port grpc of service web"},
+		},
+	}
+
+	for name, tc := range cases {
+		mismatches, err := backendConfigServiceMismatches(context.Background(), meta, "default", "web", backendConfigSpec{HealthCheck: tc.healthCheck})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", name, err)
+		}
+		if len(mismatches) != len(tc.expected) {
+			t.Errorf("%s: expected %d mismatches, got %q", name, len(tc.expected), mismatches)
+			continue
+		}
+		for i, m := range mismatches {
+			if !strings.HasPrefix(m, tc.expected[i]) {
+				t.Errorf("%s: expected %q, got %q", name, tc.expected[i], m)
+			}
+		}
+	}
+}
+
+func TestBackendConfigServiceValidationPlan(t *testing.T) {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Annotations: map[string]string{
+			backendConfigAnnotation: `{"default":"web"}`,
+			appProtocolsAnnotation:  `{"http":"HTTP"}`,
+		}},
+		Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "http", Port: 80}}},
+	}
+	meta := &apiClient{namespace: "default", clientset: fake.NewSimpleClientset(svc)}
+
+	for mode, fails := range map[string]bool{
+		serviceValidationError:   true,
+		serviceValidationWarning: false,
+		serviceValidationNone:    false,
+	} {
+		config := terraform.NewResourceConfigRaw(map[string]interface{}{
+			"service_validation": mode,
+			"metadata":           []interface{}{map[string]interface{}{"name": "web", "namespace": "default"}},
+			"spec": []interface{}{map[string]interface{}{
+				"health_check": []interface{}{map[string]interface{}{"type": "HTTP2"}},
+			}},
+		})
+		_, err := resourceBackendConfig().Diff(context.Background(), nil, config, meta)
+		if fails && (err == nil || !strings.Contains(err.Error(), "does not match the protocol HTTP of port http")) {
+			t.Errorf("%s: expected the plan to fail, got %v", mode, err)
+		}
+		if !fails && err != nil {
+			t.Errorf("%s: unexpected error: %s", mode, err)
+		}
+	}
+}
+
+// TestBackendConfigServiceValidationPlanProviderNamespace plans a backend config without a
+// namespace, which is created in the provider namespace, through the same path Terraform
+// uses.
+func TestBackendConfigServiceValidationPlanProviderNamespace(t *testing.T) {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Annotations: map[string]string{
+			backendConfigAnnotation: `{"default":"web"}`,
+			appProtocolsAnnotation:  `{"http":"HTTP"}`,
+		}},
+		Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "http", Port: 80}}},
+	}
+	p := New("dev")()
+	p.SetMeta(&apiClient{namespace: "default", clientset: fake.NewSimpleClientset(svc)})
+
+	config := testBackendConfigValue(t, `{"service_validation": "error", "metadata": [{"name": "web"}], "spec": [{"health_check": [{"type": "HTTP2"}]}]}`)
+	errs := testPlanBackendConfig(t, p, config)
+	if len(errs) == 0 || !strings.Contains(errs[0], "does not match the protocol HTTP of port http") {
+		t.Errorf("expected the plan to fail, got %q", errs)
+	}
+}
@@ -0,0 +1,413 @@
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// signedURLKeySize is the size in bytes of a Cloud CDN signed URL key (128 bits).
+	signedURLKeySize = 16
+
+	signedURLKeySecretKeyName  = "key_name"
+	signedURLKeySecretKeyValue = "key_value"
+	signedURLKeySecretExpires  = "expires_at"
+
+	signedURLKeyPreviousSuffix = "-previous"
+)
+
+func resourceBackendConfigSignedURLKey() *schema.Resource {
+	r := &schema.Resource{
+		Description:   "Generates a Cloud CDN signed URL key and stores it in a Kubernetes Secret that can be referenced from `spec.cdn.signed_url_keys` of a `backend_config`. Changing `key_name` rotates the key: the previous key is kept in a second Secret, and listed in `signed_url_keys`, until `grace_period_sec` has elapsed.",
+		CreateContext: resourceBackendConfigSignedURLKeyCreate,
+		ReadContext:   resourceBackendConfigSignedURLKeyRead,
+		UpdateContext: resourceBackendConfigSignedURLKeyUpdate,
+		DeleteContext: resourceBackendConfigSignedURLKeyDelete,
+		CustomizeDiff: resourceBackendConfigSignedURLKeyCustomizeDiff,
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+		Schema: map[string]*schema.Schema{
+			"metadata": namespacedMetadataSchema("secret", false),
+			"key_name": {
+				Type:         schema.TypeString,
+				Description:  "Name of the signed URL key, used as `KeyName` when signing URLs. Changing it generates a new key and starts the grace period of the previous one.",
+				Required:     true,
+				ValidateFunc: validateSignedURLKeyName,
+			},
+			"imported_key_value": {
+				Type:         schema.TypeString,
+				Description:  "An existing base64url-encoded 128-bit key to use instead of generating one. It is the value of `key_name`: set a new value when changing `key_name`, or remove it to have the next key generated.",
+				Optional:     true,
+				Sensitive:    true,
+				ValidateFunc: validateSignedURLKeyValue,
+			},
+			"key_value": {
+				Type:        schema.TypeString,
+				Description: "The base64url-encoded 128-bit key named `key_name`.",
+				Computed:    true,
+				Sensitive:   true,
+			},
+			"grace_period_sec": {
+				Type:         schema.TypeInt,
+				Description:  "Number of seconds the previous key is kept after a rotation, so that URLs signed with it remain valid. Set to 0 to drop the previous key immediately.",
+				Optional:     true,
+				Default:      86400,
+				ValidateFunc: validateNonNegativeInteger,
+			},
+			"previous_key_name": {
+				Type:        schema.TypeString,
+				Description: "Name of the key replaced by the last rotation, while it is within its grace period.",
+				Computed:    true,
+			},
+			"previous_key_value": {
+				Type:        schema.TypeString,
+				Description: "Value of the key replaced by the last rotation, while it is within its grace period.",
+				Computed:    true,
+				Sensitive:   true,
+			},
+			"previous_key_expires_at": {
+				Type:        schema.TypeString,
+				Description: "RFC 3339 timestamp after which the previous key is removed on the next apply.",
+				Computed:    true,
+			},
+			"signed_url_keys": {
+				Type:        schema.TypeList,
+				Description: "The keys to reference from `spec.cdn.signed_url_keys`: the current key and, during the grace period, the previous one.",
+				Computed:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"key_name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"secret_name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+			},
+		},
+	}
+	for k, v := range metadataManagementFields("secret") {
+		r.Schema[k] = v
+	}
+	return r
+}
+
+func resourceBackendConfigSignedURLKeyCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if err := customizeDiffNamespace(d, meta); err != nil {
+		return err
+	}
+	if err := customizeDiffEffectiveMetadata(d, meta); err != nil {
+		return err
+	}
+	if d.Id() == "" {
+		return nil
+	}
+
+	// Cloud CDN identifies a key by its name, so the value only changes with the name.
+	currentValue, _ := d.GetChange("key_value")
+	imported := d.Get("imported_key_value").(string)
+	importedKnown := d.NewValueKnown("imported_key_value")
+	if !d.HasChange("key_name") {
+		if d.HasChange("imported_key_value") && (!importedKnown || (imported != "" && imported != currentValue.(string))) {
+			return fmt.Errorf("imported_key_value: the value of key %q cannot change, change key_name to rotate the key", d.Get("key_name"))
+		}
+	} else {
+		switch {
+		case !importedKnown || imported == "":
+			if err := d.SetNewComputed("key_value"); err != nil {
+				return err
+			}
+		case imported == currentValue.(string):
+			return fmt.Errorf("imported_key_value: key %q would keep the value of the rotated key, set a new value or remove imported_key_value to generate one", d.Get("key_name"))
+		default:
+			if err := d.SetNew("key_value", imported); err != nil {
+				return err
+			}
+		}
+		for _, k := range []string{"previous_key_name", "previous_key_value", "previous_key_expires_at", "signed_url_keys"} {
+			if err := d.SetNewComputed(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	expiresAt := d.Get("previous_key_expires_at").(string)
+	if expiresAt == "" {
+		return nil
+	}
+	expires, err := time.Parse(time.RFC3339, expiresAt)
+	if err != nil {
+		return fmt.Errorf("previous_key_expires_at: %s", err)
+	}
+	if time.Now().Before(expires) {
+		return nil
+	}
+
+	log.Printf("[DEBUG] Previous signed URL key %q expired at %s", d.Get("previous_key_name"), expiresAt)
+	for _, k := range []string{"previous_key_name", "previous_key_value", "previous_key_expires_at"} {
+		if err := d.SetNew(k, ""); err != nil {
+			return err
+		}
+	}
+	return d.SetNewComputed("signed_url_keys")
+}
+
+func resourceBackendConfigSignedURLKeyCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	metadata := expandEffectiveMetadata(d, meta)
+	if err := meta.(*apiClient).checkNamespaceAllowed(metadata.Namespace); err != nil {
+		return diag.Errorf("metadata.0.namespace: %s", err)
+	}
+	keyValue := d.Get("imported_key_value").(string)
+	if keyValue == "" {
+		keyValue, err = generateSignedURLKey()
+		if err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	secret := signedURLKeySecret(metadata, d.Get("key_name").(string), keyValue, "")
+	log.Printf("[INFO] Creating new signed URL key secret: %s/%s", secret.Namespace, secret.Name)
+	out, err := conn.CoreV1().Secrets(metadata.Namespace).Create(ctx, secret, metav1.CreateOptions{})
+	if err != nil {
+		return diag.Errorf("Failed to create signed URL key secret: %s", err)
+	}
+	log.Printf("[INFO] Submitted new signed URL key secret: %s/%s", out.Namespace, out.Name)
+
+	d.SetId(buildId(out.ObjectMeta))
+
+	return resourceBackendConfigSignedURLKeyRead(ctx, d, meta)
+}
+
+func resourceBackendConfigSignedURLKeyRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return refreshUnreachable(d, meta, err)
+	}
+
+	namespace, name, err := idParts(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if diags, stop := refreshTerminatingNamespace(ctx, d, meta, namespace); stop {
+		return diags
+	}
+
+	log.Printf("[INFO] Reading signed URL key secret %s", name)
+	current, err := conn.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			log.Printf("[WARN] Signed URL key secret %s not found, removing from state", d.Id())
+			d.SetId("")
+			return nil
+		}
+		if isUnreachable(err) {
+			return refreshUnreachable(d, meta, err)
+		}
+		return diag.FromErr(err)
+	}
+
+	previous, err := conn.CoreV1().Secrets(namespace).Get(ctx, name+signedURLKeyPreviousSuffix, metav1.GetOptions{})
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return diag.FromErr(err)
+		}
+		previous = nil
+	}
+
+	err = setEffectiveMetadata(d, meta, current.ObjectMeta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	keys := []interface{}{
+		map[string]interface{}{
+			"key_name":    string(current.Data[signedURLKeySecretKeyName]),
+			"secret_name": current.Name,
+		},
+	}
+	attrs := map[string]interface{}{
+		"key_name":                string(current.Data[signedURLKeySecretKeyName]),
+		"key_value":               string(current.Data[signedURLKeySecretKeyValue]),
+		"previous_key_name":       "",
+		"previous_key_value":      "",
+		"previous_key_expires_at": "",
+	}
+	if previous != nil {
+		attrs["previous_key_name"] = string(previous.Data[signedURLKeySecretKeyName])
+		attrs["previous_key_value"] = string(previous.Data[signedURLKeySecretKeyValue])
+		attrs["previous_key_expires_at"] = string(previous.Data[signedURLKeySecretExpires])
+		keys = append(keys, map[string]interface{}{
+			"key_name":    string(previous.Data[signedURLKeySecretKeyName]),
+			"secret_name": previous.Name,
+		})
+	}
+	attrs["signed_url_keys"] = keys
+
+	for k, v := range attrs {
+		if err := d.Set(k, v); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	return nil
+}
+
+func resourceBackendConfigSignedURLKeyUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	namespace, name, err := idParts(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	metadata := expandEffectiveMetadata(d, meta)
+	metadata.Namespace = namespace
+	metadata.Name = name
+
+	if d.HasChange("key_name") {
+		oldName, newName := d.GetChange("key_name")
+		oldValue, _ := d.GetChange("key_value")
+		keyValue := d.Get("imported_key_value").(string)
+		if keyValue == "" {
+			keyValue, err = generateSignedURLKey()
+			if err != nil {
+				return diag.FromErr(err)
+			}
+		}
+
+		// The previous key is written first so that it is never missing while
+		// URLs signed with it may still be in use.
+		if grace := d.Get("grace_period_sec").(int); grace > 0 {
+			expiresAt := time.Now().Add(time.Duration(grace) * time.Second).UTC().Format(time.RFC3339)
+			previous := signedURLKeySecret(metadata, oldName.(string), oldValue.(string), expiresAt)
+			previous.Name = name + signedURLKeyPreviousSuffix
+			log.Printf("[INFO] Keeping previous signed URL key %q until %s", oldName, expiresAt)
+			if err := upsertSecret(ctx, conn, previous); err != nil {
+				return diag.Errorf("Failed to store previous signed URL key: %s", err)
+			}
+		} else if err := deleteSecretIfExists(ctx, conn, namespace, name+signedURLKeyPreviousSuffix); err != nil {
+			return diag.Errorf("Failed to delete previous signed URL key: %s", err)
+		}
+
+		current := signedURLKeySecret(metadata, newName.(string), keyValue, "")
+		log.Printf("[INFO] Rotating signed URL key to %q", newName)
+		if err := upsertSecret(ctx, conn, current); err != nil {
+			return diag.Errorf("Failed to rotate signed URL key: %s", err)
+		}
+	} else if d.HasChange("previous_key_expires_at") && d.Get("previous_key_expires_at").(string) == "" {
+		log.Printf("[INFO] Removing expired signed URL key %s%s", name, signedURLKeyPreviousSuffix)
+		if err := deleteSecretIfExists(ctx, conn, namespace, name+signedURLKeyPreviousSuffix); err != nil {
+			return diag.Errorf("Failed to delete previous signed URL key: %s", err)
+		}
+	}
+
+	// Also applied after a rotation, which only replaces the data of an existing Secret.
+	if d.HasChanges("metadata", "effective_labels", "effective_annotations") {
+		secret, err := conn.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		o, n := effectiveMetadataChange(d, meta, "annotations")
+		secret.Annotations = patchStringMap(secret.Annotations, o, n)
+		o, n = effectiveMetadataChange(d, meta, "labels")
+		secret.Labels = patchStringMap(secret.Labels, o, n)
+		_, err = conn.CoreV1().Secrets(namespace).Update(ctx, secret, metav1.UpdateOptions{})
+		if err != nil {
+			return diag.Errorf("Failed to update signed URL key secret: %s", err)
+		}
+	}
+
+	return resourceBackendConfigSignedURLKeyRead(ctx, d, meta)
+}
+
+func resourceBackendConfigSignedURLKeyDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	namespace, name, err := idParts(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	log.Printf("[INFO] Deleting signed URL key secrets: %s", d.Id())
+	for _, n := range []string{name, name + signedURLKeyPreviousSuffix} {
+		if err := deleteSecretIfExists(ctx, conn, namespace, n); err != nil {
+			return diag.Errorf("Failed to delete signed URL key secret %q: %s", n, err)
+		}
+	}
+
+	d.SetId("")
+	return nil
+}
+
+func generateSignedURLKey() (string, error) {
+	b := make([]byte, signedURLKeySize)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate signed URL key: %s", err)
+	}
+	return base64.URLEncoding.EncodeToString(b), nil
+}
+
+func signedURLKeySecret(metadata metav1.ObjectMeta, keyName, keyValue, expiresAt string) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metadata,
+		Type:       corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			signedURLKeySecretKeyName:  []byte(keyName),
+			signedURLKeySecretKeyValue: []byte(keyValue),
+		},
+	}
+	if expiresAt != "" {
+		secret.Data[signedURLKeySecretExpires] = []byte(expiresAt)
+	}
+	return secret
+}
+
+func upsertSecret(ctx context.Context, conn kubernetes.Interface, secret *corev1.Secret) error {
+	live, err := conn.CoreV1().Secrets(secret.Namespace).Get(ctx, secret.Name, metav1.GetOptions{})
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		_, err = conn.CoreV1().Secrets(secret.Namespace).Create(ctx, secret, metav1.CreateOptions{})
+		return err
+	}
+
+	live.Data = secret.Data
+	_, err = conn.CoreV1().Secrets(secret.Namespace).Update(ctx, live, metav1.UpdateOptions{})
+	return err
+}
+
+func deleteSecretIfExists(ctx context.Context, conn kubernetes.Interface, namespace, name string) error {
+	err := conn.CoreV1().Secrets(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
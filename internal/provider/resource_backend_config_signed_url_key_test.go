@@ -0,0 +1,186 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// testApply plans raw on top of state and applies the plan, as Terraform does.
+func testApply(r *schema.Resource, state *terraform.InstanceState, raw map[string]interface{}, meta interface{}) (*terraform.InstanceState, error) {
+	ctx := context.Background()
+	diff, err := r.Diff(ctx, state, terraform.NewResourceConfigRaw(raw), meta)
+	if err != nil {
+		return state, err
+	}
+	if diff == nil {
+		return state, nil
+	}
+	newState, diags := r.Apply(ctx, state, diff, meta)
+	if diags.HasError() {
+		return newState, fmt.Errorf("%s", diags[0].Summary)
+	}
+	return newState, nil
+}
+
+func signedURLKeyConfig(keyName string, extra map[string]interface{}) map[string]interface{} {
+	raw := map[string]interface{}{
+		"metadata": []interface{}{map[string]interface{}{"name": "cdn-key", "namespace": "default"}},
+		"key_name": keyName,
+	}
+	for k, v := range extra {
+		raw[k] = v
+	}
+	return raw
+}
+
+func TestBackendConfigSignedURLKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	meta := &apiClient{namespace: "default", clientset: fake.NewSimpleClientset()}
+	r := resourceBackendConfigSignedURLKey()
+
+	state, err := testApply(r, nil, signedURLKeyConfig("key-1", nil), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := state.Attributes["key_value"]
+	if b, err := base64.URLEncoding.DecodeString(first); err != nil || len(b) != signedURLKeySize {
+		t.Fatalf("expected a generated %d-byte key, got %q", signedURLKeySize, first)
+	}
+
+	state, err = testApply(r, state, signedURLKeyConfig("key-2", nil), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Attributes["key_value"] == first || state.Attributes["previous_key_value"] != first {
+		t.Errorf("expected a new key and the previous one kept, got %v", state.Attributes)
+	}
+	previous, err := meta.clientset.CoreV1().Secrets("default").Get(ctx, "cdn-key"+signedURLKeyPreviousSuffix, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(previous.Data[signedURLKeySecretKeyName]) != "key-1" || string(previous.Data[signedURLKeySecretExpires]) == "" {
+		t.Errorf("unexpected previous key secret %v", previous.Data)
+	}
+
+	imported := base64.URLEncoding.EncodeToString([]byte("0123456789abcdef"))
+	state, err = testApply(r, state, signedURLKeyConfig("key-3", map[string]interface{}{"imported_key_value": imported}), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Attributes["key_value"] != imported {
+		t.Errorf("expected the imported key, got %q", state.Attributes["key_value"])
+	}
+
+	// Re-planning the applied configuration must not show any change.
+	diff, err := r.Diff(ctx, state, terraform.NewResourceConfigRaw(signedURLKeyConfig("key-3", map[string]interface{}{"imported_key_value": imported})), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Empty() {
+		t.Errorf("expected no diff, got %#v", diff.Attributes)
+	}
+
+	if _, diags := r.Apply(ctx, state, &terraform.InstanceDiff{Destroy: true}, meta); diags.HasError() {
+		t.Fatalf("unexpected error: %#v", diags)
+	}
+	secrets, err := meta.clientset.CoreV1().Secrets("default").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets.Items) != 0 {
+		t.Errorf("expected both secrets to be deleted, %d left", len(secrets.Items))
+	}
+}
+
+// TestBackendConfigSignedURLKeyRotationMetadata changes the labels and annotations of the
+// Secret together with key_name.
+func TestBackendConfigSignedURLKeyRotationMetadata(t *testing.T) {
+	ctx := context.Background()
+	meta := &apiClient{namespace: "default", clientset: fake.NewSimpleClientset()}
+	r := resourceBackendConfigSignedURLKey()
+	config := func(keyName, team string) map[string]interface{} {
+		return signedURLKeyConfig(keyName, map[string]interface{}{
+			"metadata": []interface{}{map[string]interface{}{
+				"name":        "cdn-key",
+				"namespace":   "default",
+				"labels":      map[string]interface{}{"team": team},
+				"annotations": map[string]interface{}{"example.com/owner": team},
+			}},
+		})
+	}
+
+	state, err := testApply(r, nil, config("key-1", "web"), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err = testApply(r, state, config("key-2", "platform"), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret, err := meta.clientset.CoreV1().Secrets("default").Get(ctx, "cdn-key", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(secret.Data[signedURLKeySecretKeyName]) != "key-2" || secret.Labels["team"] != "platform" || secret.Annotations["example.com/owner"] != "platform" {
+		t.Errorf("expected the rotated Secret to carry the new metadata, got %v %v %v", secret.Data, secret.Labels, secret.Annotations)
+	}
+
+	diff, err := r.Diff(ctx, state, terraform.NewResourceConfigRaw(config("key-2", "platform")), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Empty() {
+		t.Errorf("expected no diff, got %#v", diff.Attributes)
+	}
+}
+
+func TestBackendConfigSignedURLKeyValueChanges(t *testing.T) {
+	meta := &apiClient{namespace: "default", clientset: fake.NewSimpleClientset()}
+	r := resourceBackendConfigSignedURLKey()
+	imported := base64.URLEncoding.EncodeToString([]byte("0123456789abcdef"))
+	other := base64.URLEncoding.EncodeToString([]byte("fedcba9876543210"))
+
+	state, err := testApply(r, nil, signedURLKeyConfig("key-1", map[string]interface{}{"imported_key_value": imported}), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		raw         map[string]interface{}
+		errContains string
+	}{
+		"value without name": {
+			raw:         signedURLKeyConfig("key-1", map[string]interface{}{"imported_key_value": other}),
+			errContains: "cannot change, change key_name",
+		},
+		"name with the same value": {
+			raw:         signedURLKeyConfig("key-2", map[string]interface{}{"imported_key_value": imported}),
+			errContains: "would keep the value of the rotated key",
+		},
+		"value removed": {
+			raw: signedURLKeyConfig("key-1", nil),
+		},
+		"name and value": {
+			raw: signedURLKeyConfig("key-2", map[string]interface{}{"imported_key_value": other}),
+		},
+	}
+
+	for name, tc := range cases {
+		_, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(tc.raw), meta)
+		if tc.errContains == "" && err != nil {
+			t.Errorf("%s: unexpected error: %s", name, err)
+		}
+		if tc.errContains != "" && (err == nil || !strings.Contains(err.Error(), tc.errContains)) {
+			t.Errorf("%s: expected an error containing %q, got %v", name, tc.errContains, err)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package provider
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// State upgraders for backend_config. Each version N keeps a frozen snapshot of the schema
+// as it was at version N, used to decode states recorded with it, and an upgrade function
+// that converts such a state to version N+1. The snapshots only need to carry the types, and
+// must not use the schema helpers of the current version, which keep changing.
+
+// resourceBackendConfigV0 describes states recorded before the schema was versioned, with
+// the spec and metadata blocks of the original resource.
+func resourceBackendConfigV0() *schema.Resource {
+	return &schema.Resource{
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(10 * time.Minute), //nolint:gomnd
+			Update: schema.DefaultTimeout(10 * time.Minute),
+			Delete: schema.DefaultTimeout(10 * time.Minute),
+		},
+		Schema: map[string]*schema.Schema{
+			"metadata": {
+				Type:     schema.TypeList,
+				Required: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"annotations":      {Type: schema.TypeMap, Optional: true, Elem: &schema.Schema{Type: schema.TypeString}},
+						"generation":       {Type: schema.TypeInt, Computed: true},
+						"labels":           {Type: schema.TypeMap, Optional: true, Elem: &schema.Schema{Type: schema.TypeString}},
+						"name":             {Type: schema.TypeString, Optional: true, Computed: true},
+						"namespace":        {Type: schema.TypeString, Optional: true},
+						"resource_version": {Type: schema.TypeString, Computed: true},
+						"self_link":        {Type: schema.TypeString, Computed: true},
+						"uid":              {Type: schema.TypeString, Computed: true},
+					},
+				},
+			},
+			"spec": {
+				Type:     schema.TypeList,
+				Required: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"timeout_sec": {Type: schema.TypeInt, Optional: true},
+						"cdn": {
+							Type:     schema.TypeList,
+							Optional: true,
+							MaxItems: 1,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"enabled": {Type: schema.TypeBool, Optional: true},
+									"cache_policy": {
+										Type:     schema.TypeList,
+										Optional: true,
+										MaxItems: 1,
+										Elem: &schema.Resource{
+											Schema: map[string]*schema.Schema{
+												"include_host":           {Type: schema.TypeBool, Optional: true},
+												"include_protocol":       {Type: schema.TypeBool, Optional: true},
+												"include_query_string":   {Type: schema.TypeBool, Optional: true},
+												"query_string_blacklist": {Type: schema.TypeSet, Optional: true, Elem: &schema.Schema{Type: schema.TypeString}},
+												"query_string_whitelist": {Type: schema.TypeSet, Optional: true, Elem: &schema.Schema{Type: schema.TypeString}},
+											},
+										},
+									},
+								},
+							},
+						},
+						"connection_draining": {
+							Type:     schema.TypeList,
+							Optional: true,
+							MaxItems: 1,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"draining_timeout_sec": {Type: schema.TypeInt, Required: true},
+								},
+							},
+						},
+						"health_check": {
+							Type:     schema.TypeList,
+							Optional: true,
+							MaxItems: 1,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"check_interval_sec":  {Type: schema.TypeInt, Optional: true},
+									"timeout_sec":         {Type: schema.TypeInt, Optional: true},
+									"healthy_threshold":   {Type: schema.TypeInt, Optional: true},
+									"unhealthy_threshold": {Type: schema.TypeInt, Optional: true},
+									"type":                {Type: schema.TypeString, Required: true},
+									"request_path":        {Type: schema.TypeString, Optional: true},
+									"port":                {Type: schema.TypeInt, Optional: true},
+								},
+							},
+						},
+						"security_policy": {
+							Type:     schema.TypeList,
+							Optional: true,
+							MaxItems: 1,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"name": {Type: schema.TypeString, Required: true},
+								},
+							},
+						},
+						"logging": {
+							Type:     schema.TypeList,
+							Optional: true,
+							MaxItems: 1,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"enable":      {Type: schema.TypeBool, Required: true},
+									"sample_rate": {Type: schema.TypeFloat, Optional: true},
+								},
+							},
+						},
+						"iap": {
+							Type:     schema.TypeList,
+							Optional: true,
+							MaxItems: 1,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"enabled":                             {Type: schema.TypeBool, Required: true},
+									"oauthclient_credentials_secret_name": {Type: schema.TypeString, Optional: true},
+								},
+							},
+						},
+						"session_affinity": {
+							Type:     schema.TypeList,
+							Optional: true,
+							MaxItems: 1,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"affinity_type":           {Type: schema.TypeString, Required: true},
+									"affinity_cookie_ttl_sec": {Type: schema.TypeInt, Optional: true},
+								},
+							},
+						},
+						"custom_request_headers": {
+							Type:     schema.TypeList,
+							Optional: true,
+							MaxItems: 1,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"headers": {Type: schema.TypeSet, Required: true, Elem: &schema.Schema{Type: schema.TypeString}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func resourceBackendConfigStateUpgradeV0(ctx context.Context, rawState map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+	log.Printf("[DEBUG] Upgrading backend_config state from version 0")
+	return rawState, nil
+}
+
+// resourceBackendConfigV1 is the original schema, which declared version 1 without changing
+// the shape of version 0.
+func resourceBackendConfigV1() *schema.Resource {
+	return resourceBackendConfigV0()
+}
+
+// resourceBackendConfigStateUpgradeV1 turns logging.sample_rate and
+// session_affinity.affinity_cookie_ttl_sec into nullable strings. Version 1 stored an unset
+// number as 0, so 0 becomes unset.
+func resourceBackendConfigStateUpgradeV1(ctx context.Context, rawState map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+	log.Printf("[DEBUG] Upgrading backend_config state from version 1")
+
+	for _, spec := range stateBlocks(rawState, "spec") {
+		for _, logging := range stateBlocks(spec, "logging") {
+			upgradeStateNumberToNullableString(logging, "sample_rate")
+		}
+		for _, sessionAffinity := range stateBlocks(spec, "session_affinity") {
+			upgradeStateNumberToNullableString(sessionAffinity, "affinity_cookie_ttl_sec")
+		}
+	}
+
+	return rawState, nil
+}
+
+// upgradeStateNumberToNullableString converts the number block[key] to its string form,
+// removing it when it is 0.
+func upgradeStateNumberToNullableString(block map[string]interface{}, key string) {
+	switch v := block[key].(type) {
+	case float64:
+		if v == 0 {
+			delete(block, key)
+		} else {
+			block[key] = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+	case nil:
+	default:
+		log.Printf("[WARN] Unexpected %s in backend_config state: %#v", key, v)
+	}
+}
+
+// stateBlocks returns the elements of the nested block key of a raw JSON state.
+func stateBlocks(rawState map[string]interface{}, key string) []map[string]interface{} {
+	l, ok := rawState[key].([]interface{})
+	if !ok {
+		return nil
+	}
+	blocks := make([]map[string]interface{}, 0, len(l))
+	for _, b := range l {
+		if m, ok := b.(map[string]interface{}); ok {
+			blocks = append(blocks, m)
+		}
+	}
+	return blocks
+}
@@ -0,0 +1,110 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+
+	ctyjson "github.com/hashicorp/go-cty/cty/json"
+	"github.com/hashicorp/go-cty/cty/msgpack"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+type stateUpgradeFixture struct {
+	Version  int64                  `json:"version"`
+	State    json.RawMessage        `json:"state"`
+	Expected map[string]interface{} `json:"expected"`
+}
+
+// TestBackendConfigStateUpgrade feeds every state recorded in testdata/state_upgrade through
+// the same upgrade path Terraform uses and checks the attributes listed as expected.
+func TestBackendConfigStateUpgrade(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "state_upgrade", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("no state upgrade fixtures found")
+	}
+
+	server := schema.NewGRPCProviderServer(New("dev")())
+	ty := resourceBackendConfig().CoreConfigSchema().ImpliedType()
+
+	for _, fixture := range fixtures {
+		fixture := fixture
+		t.Run(filepath.Base(fixture), func(t *testing.T) {
+			b, err := ioutil.ReadFile(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := stateUpgradeFixture{}
+			if err := json.Unmarshal(b, &f); err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := server.UpgradeResourceState(context.Background(), &tfprotov5.UpgradeResourceStateRequest{
+				TypeName: "backend_config",
+				Version:  f.Version,
+				RawState: &tfprotov5.RawState{JSON: f.State},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, diag := range resp.Diagnostics {
+				t.Errorf("%s: %s", diag.Summary, diag.Detail)
+			}
+			if t.Failed() {
+				return
+			}
+
+			val, err := msgpack.Unmarshal(resp.UpgradedState.MsgPack, ty)
+			if err != nil {
+				t.Fatal(err)
+			}
+			js, err := ctyjson.Marshal(val, ty)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]interface{})
+			if err := json.Unmarshal(js, &got); err != nil {
+				t.Fatal(err)
+			}
+
+			assertStateSubset(t, "", f.Expected, got)
+		})
+	}
+}
+
+// assertStateSubset reports every value in expected that differs from got. Maps only need
+// to contain the expected keys, lists must have the same length.
+func assertStateSubset(t *testing.T, path string, expected, got interface{}) {
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		if !ok {
+			t.Errorf("%s: expected an object, got %#v", path, got)
+			return
+		}
+		for k, v := range e {
+			assertStateSubset(t, path+"."+k, v, g[k])
+		}
+	case []interface{}:
+		g, ok := got.([]interface{})
+		if !ok || len(g) != len(e) {
+			t.Errorf("%s: expected %d element(s), got %#v", path, len(e), got)
+			return
+		}
+		for i := range e {
+			assertStateSubset(t, path+"."+strconv.Itoa(i), e[i], g[i])
+		}
+	default:
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("%s: expected %#v, got %#v", path, expected, got)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// appProtocolsAnnotation maps the port names of a Service to the protocol the load balancer
+// uses to reach the backends, for example {"grpc": "HTTP2"}.
+const appProtocolsAnnotation = "cloud.google.com/app-protocols"
+
+var appProtocols = []string{"HTTP", "HTTPS", "HTTP2"}
+
+func resourceServiceAppProtocols() *schema.Resource {
+	return &schema.Resource{
+		Description:   "Manages the `cloud.google.com/app-protocols` annotation of an existing Service, choosing the protocol the load balancer uses to reach each port. The other annotations of the Service are left untouched. A Service whose annotation is already set to another value must be imported. On destroy, the annotation is restored to its value from before the resource managed it. A backendconfig attached to a port with an HTTPS or HTTP2 health check that does not match the protocol of the port is reported according to `health_check_validation`.",
+		CreateContext: resourceServiceAppProtocolsCreate,
+		ReadContext:   resourceServiceAppProtocolsRead,
+		UpdateContext: resourceServiceAppProtocolsUpdate,
+		DeleteContext: resourceServiceAppProtocolsDelete,
+		CustomizeDiff: resourceServiceAppProtocolsCustomizeDiff,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceServiceAppProtocolsImportState,
+		},
+		Schema: map[string]*schema.Schema{
+			"metadata": serviceMetadataSchema(),
+			"app_protocols": {
+				Type:         schema.TypeMap,
+				Description:  "Map of Service port names to the protocol of the port: `HTTP`, `HTTPS` or `HTTP2`.",
+				Required:     true,
+				Elem:         &schema.Schema{Type: schema.TypeString},
+				ValidateFunc: validateAppProtocols,
+			},
+			"health_check_validation": {
+				Type:         schema.TypeString,
+				Description:  "How ports whose backendconfig has an HTTPS or HTTP2 health check not matching their protocol are reported. `warning` reports them on refresh and apply only, `error` also fails plans changing `app_protocols`, `none` disables the check.",
+				Optional:     true,
+				Default:      serviceValidationWarning,
+				ValidateFunc: validateAttributeValueIsIn([]string{serviceValidationError, serviceValidationWarning, serviceValidationNone}),
+			},
+			"initial_annotation": {
+				Type:        schema.TypeString,
+				Description: "Value of the annotation when the resource was created or imported, restored on destroy. Empty when the Service had none.",
+				Computed:    true,
+			},
+		},
+	}
+}
+
+// resourceServiceAppProtocolsCustomizeDiff fails the plan when health_check_validation is
+// error and a planned protocol does not match the health check of the backendconfig of its
+// port. The Service and backendconfigs are only read when the planned values are known.
+func resourceServiceAppProtocolsCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if err := customizeDiffNamespace(d, meta); err != nil {
+		return err
+	}
+
+	if d.Get("health_check_validation").(string) != serviceValidationError {
+		return nil
+	}
+	if d.Id() != "" && !d.HasChange("app_protocols") && !d.HasChange("health_check_validation") {
+		return nil
+	}
+	namespace, ok := plannedNamespace(d, meta)
+	if !ok || !d.NewValueKnown("metadata.0.name") || !d.NewValueKnown("app_protocols") {
+		return nil
+	}
+
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return err
+	}
+	name := d.Get("metadata.0.name").(string)
+	svc, err := conn.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		log.Printf("[WARN] Could not check the app protocols of service %s/%s: %s", namespace, name, err)
+		return nil
+	}
+	mismatches := appProtocolHealthCheckMismatches(ctx, meta, *svc, expandStringMap(d.Get("app_protocols").(map[string]interface{})))
+	if len(mismatches) > 0 {
+		return fmt.Errorf("app_protocols do not match the health checks of the backend configs:\n- %s", strings.Join(mismatches, "\n- "))
+	}
+	return nil
+}
+
+func resourceServiceAppProtocolsCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	namespace, name := expandServiceMetadata(d, meta)
+	if err := meta.(*apiClient).checkNamespaceAllowed(namespace); err != nil {
+		return diag.Errorf("metadata.0.namespace: %s", err)
+	}
+
+	initial, diags := applyServiceAppProtocols(ctx, d, meta, namespace, name, true)
+	if diags.HasError() {
+		return diags
+	}
+	d.SetId(buildId(metav1.ObjectMeta{Namespace: namespace, Name: name}))
+	if err := d.Set("initial_annotation", initial); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return resourceServiceAppProtocolsRead(ctx, d, meta)
+}
+
+func resourceServiceAppProtocolsRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return refreshUnreachable(d, meta, err)
+	}
+
+	namespace, name, err := idParts(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if diags, stop := refreshTerminatingNamespace(ctx, d, meta, namespace); stop {
+		return diags
+	}
+
+	log.Printf("[INFO] Reading app protocols annotation of service %s", d.Id())
+	svc, err := conn.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			log.Printf("[WARN] Service %s not found, removing from state", d.Id())
+			d.SetId("")
+			return nil
+		}
+		if isUnreachable(err) {
+			return refreshUnreachable(d, meta, err)
+		}
+		return diag.FromErr(err)
+	}
+
+	protocols, err := serviceAppProtocols(*svc)
+	if err != nil {
+		return diag.Errorf("Invalid %s annotation on service %s: %s", appProtocolsAnnotation, d.Id(), err)
+	}
+
+	attrs := map[string]interface{}{
+		"metadata":      flattenServiceMetadata(namespace, name),
+		"app_protocols": protocols,
+	}
+	for k, v := range attrs {
+		if err := d.Set(k, v); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	// Stored explicitly so that imported resources do not plan a change to the default.
+	if d.Get("health_check_validation").(string) == "" {
+		if err := d.Set("health_check_validation", serviceValidationWarning); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	if d.Get("health_check_validation").(string) == serviceValidationNone {
+		return nil
+	}
+	var diags diag.Diagnostics
+	for _, m := range appProtocolHealthCheckMismatches(ctx, meta, *svc, protocols) {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Health check protocol does not match the app protocol",
+			Detail:   m,
+		})
+	}
+	return diags
+}
+
+func resourceServiceAppProtocolsUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	namespace, name, err := idParts(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if _, diags := applyServiceAppProtocols(ctx, d, meta, namespace, name, false); diags.HasError() {
+		return diags
+	}
+
+	return resourceServiceAppProtocolsRead(ctx, d, meta)
+}
+
+func resourceServiceAppProtocolsDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	namespace, name, err := idParts(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	initial := d.Get("initial_annotation").(string)
+	log.Printf("[INFO] Restoring app protocols annotation of service %s to %q", d.Id(), initial)
+	err = updateServiceAnnotation(ctx, conn, namespace, name, appProtocolsAnnotation, func(string) (string, error) {
+		return initial, nil
+	})
+	if err != nil && !errors.IsNotFound(err) {
+		return diag.Errorf("Failed to restore app protocols annotation of service %s: %s", d.Id(), err)
+	}
+
+	d.SetId("")
+	return nil
+}
+
+func resourceServiceAppProtocolsImportState(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	namespace, name, err := idParts(d.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	svc, err := conn.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service %s: %s", d.Id(), err)
+	}
+	if err := d.Set("initial_annotation", svc.Annotations[appProtocolsAnnotation]); err != nil {
+		return nil, err
+	}
+
+	return []*schema.ResourceData{d}, nil
+}
+
+// applyServiceAppProtocols writes the configured app-protocols annotation to the Service and
+// returns the value it replaced. On creation, it refuses to take over an annotation set to
+// another value.
+func applyServiceAppProtocols(ctx context.Context, d *schema.ResourceData, meta interface{}, namespace, name string, create bool) (string, diag.Diagnostics) {
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return "", diag.FromErr(err)
+	}
+
+	value, err := json.Marshal(expandStringMap(d.Get("app_protocols").(map[string]interface{})))
+	if err != nil {
+		return "", diag.FromErr(err)
+	}
+
+	var previous string
+	log.Printf("[INFO] Setting app protocols annotation of service %s/%s: %s", namespace, name, value)
+	err = updateServiceAnnotation(ctx, conn, namespace, name, appProtocolsAnnotation, func(current string) (string, error) {
+		previous = current
+		if create && current != "" {
+			existing := map[string]string{}
+			if err := json.Unmarshal([]byte(current), &existing); err != nil {
+				return "", fmt.Errorf("invalid %s annotation: %s", appProtocolsAnnotation, err)
+			}
+			if v, _ := json.Marshal(existing); string(v) != string(value) {
+				return "", fmt.Errorf("the %s annotation is already set to %s, import it instead", appProtocolsAnnotation, current)
+			}
+		}
+		return string(value), nil
+	})
+	if err != nil {
+		return "", diag.Errorf("Failed to set app protocols annotation of service %s/%s: %s", namespace, name, err)
+	}
+	return previous, nil
+}
+
+// serviceAppProtocols parses the app-protocols annotation of svc. It is empty when the
+// Service has none.
+func serviceAppProtocols(svc corev1.Service) (map[string]string, error) {
+	protocols := map[string]string{}
+	v, ok := svc.Annotations[appProtocolsAnnotation]
+	if !ok {
+		return protocols, nil
+	}
+	err := json.Unmarshal([]byte(v), &protocols)
+	return protocols, err
+}
+
+// appProtocolHealthCheckMismatches describes the ports of svc whose backendconfig has an
+// HTTPS or HTTP2 health check while protocols gives the port another protocol, which makes
+// the backends fail their health checks.
+func appProtocolHealthCheckMismatches(ctx context.Context, meta interface{}, svc corev1.Service, protocols map[string]string) []string {
+	configs, annotation, err := expandServiceBackendConfigs(svc.Annotations)
+	if err != nil || annotation == "" {
+		return nil
+	}
+
+	portNumbers := map[string]string{}
+	for _, p := range svc.Spec.Ports {
+		portNumbers[p.Name] = strconv.Itoa(int(p.Port))
+	}
+	ports := make([]string, 0, len(protocols))
+	for port := range protocols {
+		ports = append(ports, port)
+	}
+	sort.Strings(ports)
+
+	var mismatches []string
+	healthChecks := map[string]string{}
+	for _, port := range ports {
+		name, ok := configs.Ports[port]
+		if !ok {
+			name, ok = configs.Ports[portNumbers[port]]
+		}
+		if !ok {
+			name = configs.Default
+		}
+		if name == "" {
+			continue
+		}
+
+		healthCheck, ok := healthChecks[name]
+		if !ok {
+			healthCheck, err = backendConfigHealthCheckType(ctx, meta, svc.Namespace, name)
+			if err != nil {
+				log.Printf("[DEBUG] Could not read backend config %s/%s: %s", svc.Namespace, name, err)
+			}
+			healthChecks[name] = healthCheck
+		}
+		if (healthCheck == "HTTPS" || healthCheck == "HTTP2") && healthCheck != protocols[port] {
+			mismatches = append(mismatches, fmt.Sprintf("Port %s of service %s/%s uses %s, but its backend config %q has an %s health check. The backends will fail their health checks unless they also serve %s.", port, svc.Namespace, svc.Name, protocols[port], name, healthCheck, healthCheck))
+		}
+	}
+	return mismatches
+}
+
+// backendConfigHealthCheckType returns the canonical spec.healthCheck.type of the named
+// backendconfig, empty when unset.
+func backendConfigHealthCheckType(ctx context.Context, meta interface{}, namespace, name string) (string, error) {
+	conn, err := meta.(*apiClient).DynamicClient()
+	if err != nil {
+		return "", err
+	}
+	obj, err := conn.Resource(backendConfigGVR).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	bc, err := backendConfigFromUnstructured(obj)
+	if err != nil {
+		return "", err
+	}
+	if bc.Spec.HealthCheck == nil || bc.Spec.HealthCheck.Type == nil {
+		return "", nil
+	}
+	return canonicalEnumValue(*bc.Spec.HealthCheck.Type, healthCheckTypes), nil
+}
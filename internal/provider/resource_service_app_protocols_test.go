@@ -0,0 +1,229 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// testAppProtocolsClient returns a client for a cluster with the Service web, whose grpc
+// port uses a backendconfig with an HTTP2 health check and whose other ports use one with an
+// HTTPS health check.
+func testAppProtocolsClient() *apiClient {
+	backendConfig := func(name, healthCheck string) runtime.Object {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "cloud.google.com/v1",
+			"kind":       backendConfigKind,
+			"metadata":   map[string]interface{}{"name": name, "namespace": "default"},
+			"spec": map[string]interface{}{
+				"healthCheck": map[string]interface{}{"type": healthCheck},
+			},
+		}}
+	}
+	svc := testService(map[string]string{
+		"example.com/owner":     "web",
+		backendConfigAnnotation: `{"default":"web","ports":{"8443":"grpc"}}`,
+	})
+	svc.Spec.Ports = []corev1.ServicePort{
+		{Name: "grpc", Port: 8443},
+		{Name: "http", Port: 80},
+	}
+	return &apiClient{
+		namespace:     "default",
+		clientset:     fake.NewSimpleClientset(svc),
+		dynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), backendConfig("grpc", "HTTP2"), backendConfig("web", "https")),
+	}
+}
+
+func TestServiceAppProtocols(t *testing.T) {
+	ctx := context.Background()
+	meta := testAppProtocolsClient()
+
+	d := schema.TestResourceDataRaw(t, resourceServiceAppProtocols().Schema, map[string]interface{}{
+		"metadata":      []interface{}{map[string]interface{}{"name": "web"}},
+		"app_protocols": map[string]interface{}{"grpc": "HTTP2", "http": "HTTP"},
+	})
+	diags := resourceServiceAppProtocolsCreate(ctx, d, meta)
+	if diags.HasError() {
+		t.Fatalf("unexpected error: %#v", diags)
+	}
+	if len(diags) != 1 || diags[0].Severity != diag.Warning || !strings.Contains(diags[0].Detail, `Port http of service default/web uses HTTP, but its backend config "web" has an HTTPS health check`) {
+		t.Errorf("expected a warning for port http only, got %#v", diags)
+	}
+
+	live, err := meta.clientset.CoreV1().Services("default").Get(ctx, "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := live.Annotations[appProtocolsAnnotation]; actual != `{"grpc":"HTTP2","http":"HTTP"}` {
+		t.Errorf("unexpected annotation %s", actual)
+	}
+	if live.Annotations["example.com/owner"] != "web" {
+		t.Errorf("other annotations not preserved: %v", live.Annotations)
+	}
+
+	if diags := resourceServiceAppProtocolsDelete(ctx, d, meta); diags.HasError() {
+		t.Fatalf("unexpected error: %#v", diags)
+	}
+	live, err = meta.clientset.CoreV1().Services("default").Get(ctx, "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := live.Annotations[appProtocolsAnnotation]; ok {
+		t.Error("annotation not removed")
+	}
+}
+
+func TestServiceAppProtocolsHealthCheckValidation(t *testing.T) {
+	ctx := context.Background()
+	meta := testAppProtocolsClient()
+	r := resourceServiceAppProtocols()
+
+	cases := map[string]struct {
+		protocols   map[string]interface{}
+		validation  string
+		errContains string
+	}{
+		"matching": {
+			protocols:  map[string]interface{}{"grpc": "HTTP2", "http": "HTTPS"},
+			validation: serviceValidationError,
+		},
+		"mismatch": {
+			protocols:   map[string]interface{}{"grpc": "HTTP2", "http": "HTTP"},
+			validation:  serviceValidationError,
+			errContains: `Port http of service default/web uses HTTP, but its backend config "web" has an HTTPS health check`,
+		},
+		"mismatch with warning": {
+			protocols:  map[string]interface{}{"grpc": "HTTP2", "http": "HTTP"},
+			validation: serviceValidationWarning,
+		},
+		"unknown protocols": {
+			protocols:  map[string]interface{}{"grpc": "HTTP2", "http": testUnknownValue},
+			validation: serviceValidationError,
+		},
+	}
+
+	for name, tc := range cases {
+		config := terraform.NewResourceConfigRaw(map[string]interface{}{
+			"metadata":                []interface{}{map[string]interface{}{"name": "web", "namespace": "default"}},
+			"app_protocols":           tc.protocols,
+			"health_check_validation": tc.validation,
+		})
+		_, err := r.Diff(ctx, nil, config, meta)
+		if tc.errContains == "" && err != nil {
+			t.Errorf("%s: unexpected error: %s", name, err)
+		}
+		if tc.errContains != "" && (err == nil || !strings.Contains(err.Error(), tc.errContains)) {
+			t.Errorf("%s: expected an error containing %q, got %v", name, tc.errContains, err)
+		}
+	}
+
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"metadata":                []interface{}{map[string]interface{}{"name": "web"}},
+		"app_protocols":           map[string]interface{}{"http": "HTTP"},
+		"health_check_validation": serviceValidationNone,
+	})
+	if diags := resourceServiceAppProtocolsCreate(ctx, d, meta); len(diags) > 0 {
+		t.Errorf("expected no diagnostics with the check disabled, got %#v", diags)
+	}
+}
+
+func TestServiceAppProtocolsExistingAnnotation(t *testing.T) {
+	ctx := context.Background()
+	existing := `{"http":"HTTPS"}`
+	r := resourceServiceAppProtocols()
+	meta := testServiceAnnotationClient(testService(map[string]string{appProtocolsAnnotation: existing}))
+	annotation := func() string {
+		svc, err := meta.clientset.CoreV1().Services("default").Get(ctx, "web", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return svc.Annotations[appProtocolsAnnotation]
+	}
+
+	// A different value is refused and left alone.
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"metadata":      []interface{}{map[string]interface{}{"name": "web"}},
+		"app_protocols": map[string]interface{}{"http": "HTTP"},
+	})
+	diags := resourceServiceAppProtocolsCreate(ctx, d, meta)
+	if !diags.HasError() || !strings.Contains(diags[0].Summary, "import it instead") {
+		t.Fatalf("expected the existing annotation to be refused, got %#v", diags)
+	}
+	if actual := annotation(); actual != existing {
+		t.Errorf("expected the annotation to be left alone, got %s", actual)
+	}
+
+	// The same value is taken over and kept on destroy.
+	d = schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"metadata":      []interface{}{map[string]interface{}{"name": "web"}},
+		"app_protocols": map[string]interface{}{"http": "HTTPS"},
+	})
+	if diags := resourceServiceAppProtocolsCreate(ctx, d, meta); diags.HasError() {
+		t.Fatalf("unexpected error: %#v", diags)
+	}
+	if d.Get("initial_annotation") != existing {
+		t.Errorf("expected the initial annotation to be recorded, got %q", d.Get("initial_annotation"))
+	}
+	if diags := resourceServiceAppProtocolsDelete(ctx, d, meta); diags.HasError() {
+		t.Fatalf("unexpected error: %#v", diags)
+	}
+	if actual := annotation(); actual != existing {
+		t.Errorf("expected the annotation to be kept, got %s", actual)
+	}
+}
+
+func TestServiceAppProtocolsImport(t *testing.T) {
+	ctx := context.Background()
+	original := `{"http": "HTTPS"}`
+	meta := testServiceAnnotationClient(testService(map[string]string{appProtocolsAnnotation: original}))
+	r := resourceServiceAppProtocols()
+
+	imported, err := r.Importer.StateContext(ctx, r.Data(&terraform.InstanceState{ID: "default/web"}), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diags := r.ReadContext(ctx, imported[0], meta); diags.HasError() {
+		t.Fatalf("unexpected error: %#v", diags)
+	}
+	state := imported[0].State()
+	if state.Attributes["initial_annotation"] != original {
+		t.Errorf("expected the annotation to be recorded on import, got %v", state.Attributes)
+	}
+
+	state, err = testApply(r, state, map[string]interface{}{
+		"metadata":      []interface{}{map[string]interface{}{"name": "web"}},
+		"app_protocols": map[string]interface{}{"http": "HTTP"},
+	}, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := meta.clientset.CoreV1().Services("default").Get(ctx, "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := svc.Annotations[appProtocolsAnnotation]; actual != `{"http":"HTTP"}` {
+		t.Errorf("expected the configured annotation, got %s", actual)
+	}
+
+	if _, diags := r.Apply(ctx, state, &terraform.InstanceDiff{Destroy: true}, meta); diags.HasError() {
+		t.Fatalf("unexpected error: %#v", diags)
+	}
+	svc, err = meta.clientset.CoreV1().Services("default").Get(ctx, "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := svc.Annotations[appProtocolsAnnotation]; actual != original {
+		t.Errorf("expected the original annotation to be restored, got %s", actual)
+	}
+}
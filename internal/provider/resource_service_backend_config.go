@@ -0,0 +1,240 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func resourceServiceBackendConfig() *schema.Resource {
+	return &schema.Resource{
+		Description:   "Attaches backendconfigs to an existing Service through its `cloud.google.com/backend-config` annotation. The resource owns the whole annotation; the other annotations of the Service are left untouched. A Service that already has a different annotation must be imported. The backendconfigs must exist when planning, unless their names are only known at apply time.",
+		CreateContext: resourceServiceBackendConfigCreate,
+		ReadContext:   resourceServiceBackendConfigRead,
+		UpdateContext: resourceServiceBackendConfigUpdate,
+		DeleteContext: resourceServiceBackendConfigDelete,
+		CustomizeDiff: resourceServiceBackendConfigCustomizeDiff,
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+		Schema: map[string]*schema.Schema{
+			"metadata": serviceMetadataSchema(),
+			"default": {
+				Type:         schema.TypeString,
+				Description:  "Name of the backendconfig used by every port of the Service without a port-specific backendconfig.",
+				Optional:     true,
+				ValidateFunc: validateName,
+				AtLeastOneOf: []string{"default", "ports"},
+			},
+			"ports": {
+				Type:         schema.TypeMap,
+				Description:  "Map of Service port names or numbers to the name of the backendconfig used for that port.",
+				Optional:     true,
+				Elem:         &schema.Schema{Type: schema.TypeString},
+				ValidateFunc: validateBackendConfigPorts,
+				AtLeastOneOf: []string{"default", "ports"},
+			},
+		},
+	}
+}
+
+func resourceServiceBackendConfigCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if err := customizeDiffNamespace(d, meta); err != nil {
+		return err
+	}
+
+	// The backendconfigs are looked up now when their names are known, otherwise on apply.
+	if d.Id() != "" && !d.HasChange("default") && !d.HasChange("ports") {
+		return nil
+	}
+	namespace, ok := plannedNamespace(d, meta)
+	if !ok || !d.NewValueKnown("default") || !d.NewValueKnown("ports") {
+		return nil
+	}
+	configs := serviceBackendConfigs{
+		Default: d.Get("default").(string),
+		Ports:   expandStringMap(d.Get("ports").(map[string]interface{})),
+	}
+	return checkBackendConfigsExist(ctx, meta, namespace, configs)
+}
+
+func resourceServiceBackendConfigCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	namespace, name := expandServiceMetadata(d, meta)
+	if err := meta.(*apiClient).checkNamespaceAllowed(namespace); err != nil {
+		return diag.Errorf("metadata.0.namespace: %s", err)
+	}
+
+	if diags := applyServiceBackendConfig(ctx, d, meta, namespace, name, true); diags.HasError() {
+		return diags
+	}
+	d.SetId(buildId(metav1.ObjectMeta{Namespace: namespace, Name: name}))
+
+	return resourceServiceBackendConfigRead(ctx, d, meta)
+}
+
+func resourceServiceBackendConfigRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return refreshUnreachable(d, meta, err)
+	}
+
+	namespace, name, err := idParts(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if diags, stop := refreshTerminatingNamespace(ctx, d, meta, namespace); stop {
+		return diags
+	}
+
+	log.Printf("[INFO] Reading backend config annotation of service %s", d.Id())
+	svc, err := conn.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			log.Printf("[WARN] Service %s not found, removing from state", d.Id())
+			d.SetId("")
+			return nil
+		}
+		if isUnreachable(err) {
+			return refreshUnreachable(d, meta, err)
+		}
+		return diag.FromErr(err)
+	}
+
+	// A missing annotation is read as empty, so that the next apply writes it again.
+	configs := serviceBackendConfigs{}
+	if v, ok := svc.Annotations[backendConfigAnnotation]; ok {
+		if err := json.Unmarshal([]byte(v), &configs); err != nil {
+			return diag.Errorf("Invalid %s annotation on service %s: %s", backendConfigAnnotation, d.Id(), err)
+		}
+	}
+
+	attrs := map[string]interface{}{
+		"metadata": flattenServiceMetadata(namespace, name),
+		"default":  configs.Default,
+		"ports":    configs.Ports,
+	}
+	for k, v := range attrs {
+		if err := d.Set(k, v); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	return nil
+}
+
+func resourceServiceBackendConfigUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	namespace, name, err := idParts(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if diags := applyServiceBackendConfig(ctx, d, meta, namespace, name, false); diags.HasError() {
+		return diags
+	}
+
+	return resourceServiceBackendConfigRead(ctx, d, meta)
+}
+
+func resourceServiceBackendConfigDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	namespace, name, err := idParts(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	log.Printf("[INFO] Removing backend config annotation of service %s", d.Id())
+	err = updateServiceAnnotation(ctx, conn, namespace, name, backendConfigAnnotation, func(string) (string, error) {
+		return "", nil
+	})
+	if err != nil && !errors.IsNotFound(err) {
+		return diag.Errorf("Failed to remove backend config annotation of service %s: %s", d.Id(), err)
+	}
+
+	d.SetId("")
+	return nil
+}
+
+// applyServiceBackendConfig writes the configured backendconfigs to the annotation of the
+// Service, once they are all known to exist. On creation, it refuses to take over an
+// annotation set to other backendconfigs.
+func applyServiceBackendConfig(ctx context.Context, d *schema.ResourceData, meta interface{}, namespace, name string, create bool) diag.Diagnostics {
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	configs := serviceBackendConfigs{
+		Default: d.Get("default").(string),
+		Ports:   expandStringMap(d.Get("ports").(map[string]interface{})),
+	}
+	if err := checkBackendConfigsExist(ctx, meta, namespace, configs); err != nil {
+		return diag.FromErr(err)
+	}
+	value, err := json.Marshal(configs)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	log.Printf("[INFO] Setting backend config annotation of service %s/%s: %s", namespace, name, value)
+	err = updateServiceAnnotation(ctx, conn, namespace, name, backendConfigAnnotation, func(current string) (string, error) {
+		if create && current != "" {
+			existing := serviceBackendConfigs{}
+			if err := json.Unmarshal([]byte(current), &existing); err != nil {
+				return "", fmt.Errorf("invalid %s annotation: %s", backendConfigAnnotation, err)
+			}
+			if !existing.equal(configs) {
+				return "", fmt.Errorf("the %s annotation is already set to %s, import it instead", backendConfigAnnotation, current)
+			}
+		}
+		return string(value), nil
+	})
+	if err != nil {
+		return diag.Errorf("Failed to set backend config annotation of service %s/%s: %s", namespace, name, err)
+	}
+	return nil
+}
+
+// checkBackendConfigsExist fails when a backendconfig referenced by configs is not in the
+// namespace, which the GKE ingress controller would only report as an event of the Service.
+func checkBackendConfigsExist(ctx context.Context, meta interface{}, namespace string, configs serviceBackendConfigs) error {
+	conn, err := meta.(*apiClient).DynamicClient()
+	if err != nil {
+		return err
+	}
+
+	names := map[string]bool{}
+	if configs.Default != "" {
+		names[configs.Default] = true
+	}
+	for _, n := range configs.Ports {
+		names[n] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for n := range names {
+		sorted = append(sorted, n)
+	}
+	sort.Strings(sorted)
+
+	for _, n := range sorted {
+		_, err := conn.Resource(backendConfigGVR).Namespace(namespace).Get(ctx, n, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return fmt.Errorf("backend config %q not found in namespace %q", n, namespace)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read backend config %s/%s: %s", namespace, n, err)
+		}
+	}
+	return nil
+}
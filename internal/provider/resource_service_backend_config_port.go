@@ -0,0 +1,213 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func resourceServiceBackendConfigPort() *schema.Resource {
+	return &schema.Resource{
+		Description:   "Attaches a backendconfig to one port of an existing Service, as an entry of the `ports` map of its `cloud.google.com/backend-config` annotation. Several of these resources, possibly in different configurations, can share the annotation of a Service, each owning the entry of its port. Do not combine with `service_backend_config` on the same Service, which owns the whole annotation.",
+		CreateContext: resourceServiceBackendConfigPortCreate,
+		ReadContext:   resourceServiceBackendConfigPortRead,
+		UpdateContext: resourceServiceBackendConfigPortUpdate,
+		DeleteContext: resourceServiceBackendConfigPortDelete,
+		CustomizeDiff: resourceServiceAnnotationCustomizeDiff,
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+		Schema: map[string]*schema.Schema{
+			"metadata": serviceMetadataSchema(),
+			"port": {
+				Type:         schema.TypeString,
+				Description:  "Name or number of the Service port, the key of the entry in `ports`.",
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validatePortNumOrName,
+			},
+			"backend_config": {
+				Type:         schema.TypeString,
+				Description:  "Name of the backendconfig used for the port.",
+				Required:     true,
+				ValidateFunc: validateName,
+			},
+		},
+	}
+}
+
+func resourceServiceBackendConfigPortCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	namespace, name := expandServiceMetadata(d, meta)
+	if err := meta.(*apiClient).checkNamespaceAllowed(namespace); err != nil {
+		return diag.Errorf("metadata.0.namespace: %s", err)
+	}
+	port := d.Get("port").(string)
+
+	if diags := applyServiceBackendConfigPort(ctx, d, meta, namespace, name, port, true); diags.HasError() {
+		return diags
+	}
+	d.SetId(buildServicePortId(namespace, name, port))
+
+	return resourceServiceBackendConfigPortRead(ctx, d, meta)
+}
+
+func resourceServiceBackendConfigPortRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return refreshUnreachable(d, meta, err)
+	}
+
+	namespace, name, port, err := servicePortIdParts(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if diags, stop := refreshTerminatingNamespace(ctx, d, meta, namespace); stop {
+		return diags
+	}
+
+	log.Printf("[INFO] Reading backend config of port %s of service %s/%s", port, namespace, name)
+	svc, err := conn.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			log.Printf("[WARN] Service %s/%s not found, removing from state", namespace, name)
+			d.SetId("")
+			return nil
+		}
+		if isUnreachable(err) {
+			return refreshUnreachable(d, meta, err)
+		}
+		return diag.FromErr(err)
+	}
+
+	configs := serviceBackendConfigs{}
+	if v, ok := svc.Annotations[backendConfigAnnotation]; ok {
+		if err := json.Unmarshal([]byte(v), &configs); err != nil {
+			return diag.Errorf("Invalid %s annotation on service %s/%s: %s", backendConfigAnnotation, namespace, name, err)
+		}
+	}
+	backendConfig, ok := configs.Ports[port]
+	if !ok {
+		log.Printf("[WARN] Port %s not found in the backend config annotation of service %s/%s, removing from state", port, namespace, name)
+		d.SetId("")
+		return nil
+	}
+
+	attrs := map[string]interface{}{
+		"metadata":       flattenServiceMetadata(namespace, name),
+		"port":           port,
+		"backend_config": backendConfig,
+	}
+	for k, v := range attrs {
+		if err := d.Set(k, v); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	return nil
+}
+
+func resourceServiceBackendConfigPortUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	namespace, name, port, err := servicePortIdParts(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if diags := applyServiceBackendConfigPort(ctx, d, meta, namespace, name, port, false); diags.HasError() {
+		return diags
+	}
+
+	return resourceServiceBackendConfigPortRead(ctx, d, meta)
+}
+
+func resourceServiceBackendConfigPortDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	namespace, name, port, err := servicePortIdParts(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	log.Printf("[INFO] Removing port %s from the backend config annotation of service %s/%s", port, namespace, name)
+	err = updateServiceAnnotation(ctx, conn, namespace, name, backendConfigAnnotation, func(value string) (string, error) {
+		return patchServiceBackendConfigPort(value, port, "")
+	})
+	if err != nil && !errors.IsNotFound(err) {
+		return diag.Errorf("Failed to remove port %s from the backend config annotation of service %s/%s: %s", port, namespace, name, err)
+	}
+
+	d.SetId("")
+	return nil
+}
+
+// applyServiceBackendConfigPort sets the entry of port in the annotation of the Service. On
+// creation, it refuses to take over an entry set to another backendconfig.
+func applyServiceBackendConfigPort(ctx context.Context, d *schema.ResourceData, meta interface{}, namespace, name, port string, create bool) diag.Diagnostics {
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	backendConfig := d.Get("backend_config").(string)
+	if err := checkBackendConfigsExist(ctx, meta, namespace, serviceBackendConfigs{Default: backendConfig}); err != nil {
+		return diag.FromErr(err)
+	}
+
+	log.Printf("[INFO] Setting backend config of port %s of service %s/%s to %s", port, namespace, name, backendConfig)
+	err = updateServiceAnnotation(ctx, conn, namespace, name, backendConfigAnnotation, func(value string) (string, error) {
+		if create && value != "" {
+			configs := serviceBackendConfigs{}
+			if err := json.Unmarshal([]byte(value), &configs); err != nil {
+				return "", fmt.Errorf("invalid %s annotation: %s", backendConfigAnnotation, err)
+			}
+			if current, ok := configs.Ports[port]; ok && current != backendConfig {
+				return "", fmt.Errorf("port %s already uses backend config %q, import it instead", port, current)
+			}
+		}
+		return patchServiceBackendConfigPort(value, port, backendConfig)
+	})
+	if err != nil {
+		return diag.Errorf("Failed to set backend config of port %s of service %s/%s: %s", port, namespace, name, err)
+	}
+	return nil
+}
+
+// patchServiceBackendConfigPort returns the backend-config annotation value with the entry
+// of port set to backendConfig, or removed when backendConfig is empty. The other entries
+// are preserved.
+func patchServiceBackendConfigPort(value, port, backendConfig string) (string, error) {
+	return patchServiceBackendConfigs(value, func(configs *serviceBackendConfigs) {
+		if backendConfig == "" {
+			delete(configs.Ports, port)
+			return
+		}
+		if configs.Ports == nil {
+			configs.Ports = make(map[string]string)
+		}
+		configs.Ports[port] = backendConfig
+	})
+}
+
+func buildServicePortId(namespace, name, port string) string {
+	return namespace + "/" + name + "/" + port
+}
+
+func servicePortIdParts(id string) (string, string, string, error) {
+	parts := strings.Split(id, "/")
+	if len(parts) != 3 {
+		err := fmt.Errorf("unexpected ID format (%q), expected %q.", id, "namespace/service/port")
+		return "", "", "", err
+	}
+
+	return parts[0], parts[1], parts[2], nil
+}
@@ -0,0 +1,116 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestServiceBackendConfigPort(t *testing.T) {
+	ctx := context.Background()
+	meta := testServiceAnnotationClient(testService(map[string]string{
+		backendConfigAnnotation: `{"default":"web"}`,
+	}), "web", "web-http", "web-tls")
+
+	// Another team updates the Service between our read and our write.
+	conflicts := 0
+	meta.clientset.(*fake.Clientset).PrependReactor("update", "services", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if conflicts == 0 {
+			conflicts++
+			return true, nil, errors.NewConflict(corev1.Resource("services"), "web", nil)
+		}
+		return false, nil, nil
+	})
+
+	r := resourceServiceBackendConfigPort()
+	ports := map[string]*schema.ResourceData{}
+	for port, backendConfig := range map[string]string{"http": "web-http", "8443": "web-tls"} {
+		d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+			"metadata":       []interface{}{map[string]interface{}{"name": "web"}},
+			"port":           port,
+			"backend_config": backendConfig,
+		})
+		if diags := resourceServiceBackendConfigPortCreate(ctx, d, meta); diags.HasError() {
+			t.Fatalf("%s: unexpected error: %#v", port, diags)
+		}
+		ports[port] = d
+	}
+	if conflicts != 1 {
+		t.Errorf("expected one conflict, got %d", conflicts)
+	}
+	if id := ports["8443"].Id(); id != "default/web/8443" {
+		t.Errorf("unexpected ID %q", id)
+	}
+	expectServiceAnnotation(t, meta, `{"default":"web","ports":{"8443":"web-tls","http":"web-http"}}`)
+
+	// Taking over the entry of another resource is refused.
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"metadata":       []interface{}{map[string]interface{}{"name": "web"}},
+		"port":           "http",
+		"backend_config": "web",
+	})
+	diags := resourceServiceBackendConfigPortCreate(ctx, d, meta)
+	if !diags.HasError() || !strings.Contains(diags[0].Summary, `already uses backend config "web-http"`) {
+		t.Fatalf("expected an error for the port already in use, got %#v", diags)
+	}
+
+	if diags := resourceServiceBackendConfigPortDelete(ctx, ports["http"], meta); diags.HasError() {
+		t.Fatalf("unexpected error: %#v", diags)
+	}
+	expectServiceAnnotation(t, meta, `{"default":"web","ports":{"8443":"web-tls"}}`)
+
+	// Only the entry of the destroyed resource is gone.
+	if diags := resourceServiceBackendConfigPortRead(ctx, ports["8443"], meta); diags.HasError() || ports["8443"].Id() == "" {
+		t.Fatalf("expected port 8443 to be kept, got %#v", diags)
+	}
+	d = r.Data(&terraform.InstanceState{ID: "default/web/http"})
+	if diags := resourceServiceBackendConfigPortRead(ctx, d, meta); diags.HasError() || d.Id() != "" {
+		t.Fatalf("expected port http to be removed from state, got %#v", diags)
+	}
+}
+
+func TestPatchServiceBackendConfigPort(t *testing.T) {
+	cases := []struct {
+		value         string
+		port          string
+		backendConfig string
+		expected      string
+	}{
+		{"", "http", "web", `{"ports":{"http":"web"}}`},
+		{`{"default":"web"}`, "http", "web-http", `{"default":"web","ports":{"http":"web-http"}}`},
+		{`{"ports":{"http":"web"}}`, "http", "", ""},
+		{`{"default":"web","ports":{"http":"web"}}`, "http", "", `{"default":"web"}`},
+		{`{"ports":{"http":"web"}}`, "https", "", `{"ports":{"http":"web"}}`},
+	}
+
+	for _, tc := range cases {
+		actual, err := patchServiceBackendConfigPort(tc.value, tc.port, tc.backendConfig)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.value, err)
+			continue
+		}
+		if actual != tc.expected {
+			t.Errorf("%s with %s=%q: expected %s, got %s", tc.value, tc.port, tc.backendConfig, tc.expected, actual)
+		}
+	}
+}
+
+func expectServiceAnnotation(t *testing.T, meta *apiClient, expected string) {
+	t.Helper()
+	svc, err := meta.clientset.CoreV1().Services("default").Get(context.Background(), "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := svc.Annotations[backendConfigAnnotation]; actual != expected {
+		t.Errorf("expected annotation %s, got %s", expected, actual)
+	}
+}
@@ -0,0 +1,391 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+func resourceServiceBackendConfigSelector() *schema.Resource {
+	return &schema.Resource{
+		Description:   "Sets a backendconfig as the `default` of the `cloud.google.com/backend-config` annotation of every Service of a namespace matching a label selector. Services created or labelled after the last apply show up as a change to `services` in the next plan. Services whose annotation already has another `default` are left untouched, and the `ports` of the annotation are always preserved. A Service already using the backendconfig as its `default` without this selector having set it, for example because another selector of the same backendconfig matches it, fails the apply: selectors of a backendconfig must not overlap. On destroy, only the Services the selector annotated are unannotated.",
+		CreateContext: resourceServiceBackendConfigSelectorCreate,
+		ReadContext:   resourceServiceBackendConfigSelectorRead,
+		UpdateContext: resourceServiceBackendConfigSelectorUpdate,
+		DeleteContext: resourceServiceBackendConfigSelectorDelete,
+		CustomizeDiff: resourceServiceBackendConfigSelectorCustomizeDiff,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceServiceBackendConfigSelectorImportState,
+		},
+		Schema: map[string]*schema.Schema{
+			"metadata": serviceSelectorMetadataSchema(),
+			"selector": {
+				Type:         schema.TypeMap,
+				Description:  "Labels a Service must all have to be annotated. Services that no longer match are no longer annotated after the next apply.",
+				Required:     true,
+				Elem:         &schema.Schema{Type: schema.TypeString},
+				ValidateFunc: validateLabelSelector,
+			},
+			"backend_config": {
+				Type:         schema.TypeString,
+				Description:  "Name of the backendconfig set as the `default` of the matching Services.",
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validateName,
+			},
+			"services": {
+				Type:        schema.TypeSet,
+				Description: "Names of the Services the selector annotated with the backendconfig.",
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Set:         schema.HashString,
+			},
+			"matching_services": {
+				Type:        schema.TypeSet,
+				Description: "Names of the Services matching `selector` on the last refresh, except the ones whose `default` was set by something else than this selector.",
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Set:         schema.HashString,
+			},
+		},
+	}
+}
+
+func resourceServiceBackendConfigSelectorCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if err := customizeDiffNamespace(d, meta); err != nil {
+		return err
+	}
+	if d.Id() == "" {
+		return nil
+	}
+
+	if d.HasChange("selector") {
+		for _, k := range []string{"services", "matching_services"} {
+			if err := d.SetNewComputed(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	// Services that started or stopped matching the selector since the last apply.
+	matching := d.Get("matching_services").(*schema.Set)
+	if !matching.Equal(d.Get("services")) {
+		return d.SetNew("services", matching.List())
+	}
+	return nil
+}
+
+func resourceServiceBackendConfigSelectorCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	namespace, _ := expandServiceMetadata(d, meta)
+	if err := meta.(*apiClient).checkNamespaceAllowed(namespace); err != nil {
+		return diag.Errorf("metadata.0.namespace: %s", err)
+	}
+	backendConfig := d.Get("backend_config").(string)
+
+	if diags := applyServiceBackendConfigSelector(ctx, d, meta, namespace, backendConfig); diags.HasError() {
+		return diags
+	}
+	d.SetId(buildServiceSelectorId(namespace, backendConfig, expandStringMap(d.Get("selector").(map[string]interface{}))))
+
+	return resourceServiceBackendConfigSelectorRead(ctx, d, meta)
+}
+
+func resourceServiceBackendConfigSelectorRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return refreshUnreachable(d, meta, err)
+	}
+
+	namespace, backendConfig, _, err := serviceSelectorIdParts(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if diags, stop := refreshTerminatingNamespace(ctx, d, meta, namespace); stop {
+		return diags
+	}
+
+	selector := expandStringMap(d.Get("selector").(map[string]interface{}))
+	log.Printf("[INFO] Listing services of %s matching %v", namespace, selector)
+	services, err := listServicesBySelector(ctx, conn, namespace, selector)
+	if err != nil {
+		if isUnreachable(err) {
+			return refreshUnreachable(d, meta, err)
+		}
+		return diag.FromErr(err)
+	}
+
+	owned := map[string]bool{}
+	for _, v := range d.Get("services").(*schema.Set).List() {
+		owned[v.(string)] = true
+	}
+
+	var diags diag.Diagnostics
+	var annotated, matching []string
+	matched := map[string]bool{}
+	for _, svc := range services {
+		matched[svc.Name] = true
+		configs, err := serviceBackendConfigAnnotation(svc)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "Invalid backend config annotation",
+				Detail:   fmt.Sprintf("Service %s/%s matches the selector but its %s annotation is invalid: %s", namespace, svc.Name, backendConfigAnnotation, err),
+			})
+			continue
+		}
+		switch configs.Default {
+		case backendConfig:
+			if !owned[svc.Name] {
+				diags = append(diags, diag.Diagnostic{
+					Severity: diag.Warning,
+					Summary:  "Service annotated by another resource",
+					Detail:   fmt.Sprintf("Service %s/%s matches the selector but already uses backend config %q as its default, set by another selector or outside of this resource. It is left untouched.", namespace, svc.Name, backendConfig),
+				})
+				continue
+			}
+			annotated = append(annotated, svc.Name)
+			matching = append(matching, svc.Name)
+		case "":
+			matching = append(matching, svc.Name)
+		default:
+			log.Printf("[DEBUG] Service %s/%s uses backend config %q by default, leaving it untouched", namespace, svc.Name, configs.Default)
+		}
+	}
+
+	// Services that stopped matching keep the backendconfig until the next apply.
+	for name := range owned {
+		if matched[name] {
+			continue
+		}
+		svc, err := conn.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return diag.FromErr(err)
+		}
+		configs, err := serviceBackendConfigAnnotation(*svc)
+		if err == nil && configs.Default == backendConfig {
+			annotated = append(annotated, name)
+		}
+	}
+
+	attrs := map[string]interface{}{
+		"metadata":          []interface{}{map[string]interface{}{"namespace": namespace}},
+		"backend_config":    backendConfig,
+		"services":          annotated,
+		"matching_services": matching,
+	}
+	for k, v := range attrs {
+		if err := d.Set(k, v); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	return diags
+}
+
+func resourceServiceBackendConfigSelectorUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	namespace, backendConfig, _, err := serviceSelectorIdParts(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if diags := applyServiceBackendConfigSelector(ctx, d, meta, namespace, backendConfig); diags.HasError() {
+		return diags
+	}
+	d.SetId(buildServiceSelectorId(namespace, backendConfig, expandStringMap(d.Get("selector").(map[string]interface{}))))
+
+	return resourceServiceBackendConfigSelectorRead(ctx, d, meta)
+}
+
+func resourceServiceBackendConfigSelectorDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	namespace, backendConfig, _, err := serviceSelectorIdParts(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	for _, name := range sliceOfString(d.Get("services").(*schema.Set).List()) {
+		log.Printf("[INFO] Removing backend config %s from service %s/%s", backendConfig, namespace, name)
+		if err := removeServiceBackendConfigDefault(ctx, conn, namespace, name, backendConfig); err != nil {
+			return diag.Errorf("Failed to remove backend config of service %s/%s: %s", namespace, name, err)
+		}
+	}
+
+	d.SetId("")
+	return nil
+}
+
+func resourceServiceBackendConfigSelectorImportState(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	namespace, backendConfig, selector, err := serviceSelectorIdParts(d.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	// The matching Services already using the backendconfig are taken over.
+	services, err := listServicesBySelector(ctx, conn, namespace, selector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services of %s: %s", namespace, err)
+	}
+	var annotated []string
+	for _, svc := range services {
+		if configs, err := serviceBackendConfigAnnotation(svc); err == nil && configs.Default == backendConfig {
+			annotated = append(annotated, svc.Name)
+		}
+	}
+
+	attrs := map[string]interface{}{
+		"metadata":       []interface{}{map[string]interface{}{"namespace": namespace}},
+		"backend_config": backendConfig,
+		"selector":       selector,
+		"services":       annotated,
+	}
+	for k, v := range attrs {
+		if err := d.Set(k, v); err != nil {
+			return nil, err
+		}
+	}
+	d.SetId(buildServiceSelectorId(namespace, backendConfig, selector))
+
+	return []*schema.ResourceData{d}, nil
+}
+
+// applyServiceBackendConfigSelector annotates the Services currently matching the selector,
+// and removes the backendconfig from the ones annotated before that no longer match. It
+// refuses to take over a matching Service whose default is already the backendconfig but
+// was not set by this selector, and records the Services it annotated in services.
+func applyServiceBackendConfigSelector(ctx context.Context, d *schema.ResourceData, meta interface{}, namespace, backendConfig string) diag.Diagnostics {
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := checkBackendConfigsExist(ctx, meta, namespace, serviceBackendConfigs{Default: backendConfig}); err != nil {
+		return diag.FromErr(err)
+	}
+
+	services, err := listServicesBySelector(ctx, conn, namespace, expandStringMap(d.Get("selector").(map[string]interface{})))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	previous, _ := d.GetChange("services")
+	owned := map[string]bool{}
+	for _, name := range sliceOfString(previous.(*schema.Set).List()) {
+		owned[name] = true
+	}
+	for _, svc := range services {
+		configs, err := serviceBackendConfigAnnotation(svc)
+		if err == nil && configs.Default == backendConfig && !owned[svc.Name] {
+			return diag.Errorf("Service %s/%s already uses backend config %q as its default, set by another selector or outside of this resource. Selectors of a backendconfig must not match the same Services.", namespace, svc.Name, backendConfig)
+		}
+	}
+
+	var annotated []string
+	matched := map[string]bool{}
+	for _, svc := range services {
+		matched[svc.Name] = true
+		log.Printf("[INFO] Setting backend config of service %s/%s to %s", namespace, svc.Name, backendConfig)
+		var set bool
+		err := updateServiceAnnotation(ctx, conn, namespace, svc.Name, backendConfigAnnotation, func(value string) (string, error) {
+			return patchServiceBackendConfigs(value, func(configs *serviceBackendConfigs) {
+				if configs.Default == "" {
+					configs.Default = backendConfig
+				}
+				set = configs.Default == backendConfig
+			})
+		})
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return diag.Errorf("Failed to set backend config of service %s/%s: %s", namespace, svc.Name, err)
+		}
+		if set {
+			annotated = append(annotated, svc.Name)
+		}
+	}
+	if err := d.Set("services", annotated); err != nil {
+		return diag.FromErr(err)
+	}
+
+	for name := range owned {
+		if matched[name] {
+			continue
+		}
+		log.Printf("[INFO] Removing backend config %s from service %s/%s", backendConfig, namespace, name)
+		if err := removeServiceBackendConfigDefault(ctx, conn, namespace, name, backendConfig); err != nil {
+			return diag.Errorf("Failed to remove backend config of service %s/%s: %s", namespace, name, err)
+		}
+	}
+	return nil
+}
+
+func listServicesBySelector(ctx context.Context, conn kubernetes.Interface, namespace string, selector map[string]string) ([]corev1.Service, error) {
+	list, err := conn.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(selector).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].Name < list.Items[j].Name })
+	return list.Items, nil
+}
+
+// removeServiceBackendConfigDefault removes backendConfig from the default of the annotation
+// of a Service, unless another backendconfig was set as the default since.
+func removeServiceBackendConfigDefault(ctx context.Context, conn kubernetes.Interface, namespace, name, backendConfig string) error {
+	err := updateServiceAnnotation(ctx, conn, namespace, name, backendConfigAnnotation, func(value string) (string, error) {
+		return patchServiceBackendConfigs(value, func(configs *serviceBackendConfigs) {
+			if configs.Default == backendConfig {
+				configs.Default = ""
+			}
+		})
+	})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// buildServiceSelectorId returns the ID of the selector of backendConfig, for example
+// default/security-policy/tier=public. It includes the selector so that several selectors
+// matching distinct Services can share a backendconfig.
+func buildServiceSelectorId(namespace, backendConfig string, selector map[string]string) string {
+	return namespace + "/" + backendConfig + "/" + labels.SelectorFromSet(selector).String()
+}
+
+func serviceSelectorIdParts(id string) (string, string, map[string]string, error) {
+	parts := strings.SplitN(id, "/", 3)
+	if len(parts) != 3 || parts[2] == "" {
+		err := fmt.Errorf("unexpected ID format (%q), expected %q.", id, "namespace/backend_config/selector")
+		return "", "", nil, err
+	}
+
+	selector, err := labels.ConvertSelectorToLabelsMap(parts[2])
+	if err != nil {
+		return "", "", nil, fmt.Errorf("invalid selector in ID %q: %s", id, err)
+	}
+
+	return parts[0], parts[1], selector, nil
+}
@@ -0,0 +1,254 @@
+package provider
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestServiceBackendConfigSelector(t *testing.T) {
+	ctx := context.Background()
+	service := func(name, tier string, annotations map[string]string) runtime.Object {
+		return &corev1.Service{ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "default",
+			Labels:      map[string]string{"tier": tier},
+			Annotations: annotations,
+		}}
+	}
+	meta := &apiClient{
+		namespace: "default",
+		clientset: fake.NewSimpleClientset(
+			service("api", "public", nil),
+			service("web", "public", map[string]string{backendConfigAnnotation: `{"ports":{"http":"web-http"}}`}),
+			service("custom", "public", map[string]string{backendConfigAnnotation: `{"default":"custom"}`}),
+			service("db", "internal", nil),
+		),
+		dynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "cloud.google.com/v1",
+			"kind":       backendConfigKind,
+			"metadata":   map[string]interface{}{"name": "security-policy", "namespace": "default"},
+		}}),
+	}
+	annotations := func() map[string]string {
+		list, err := meta.clientset.CoreV1().Services("default").List(ctx, metav1.ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := map[string]string{}
+		for _, svc := range list.Items {
+			out[svc.Name] = svc.Annotations[backendConfigAnnotation]
+		}
+		return out
+	}
+	services := func(d *schema.ResourceData) []string {
+		out := sliceOfString(d.Get("services").(*schema.Set).List())
+		sort.Strings(out)
+		return out
+	}
+
+	r := resourceServiceBackendConfigSelector()
+	config := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"metadata":       []interface{}{map[string]interface{}{"namespace": "default"}},
+		"selector":       map[string]interface{}{"tier": "public"},
+		"backend_config": "security-policy",
+	})
+	d := schema.TestResourceDataRaw(t, r.Schema, config.Raw)
+	if diags := resourceServiceBackendConfigSelectorCreate(ctx, d, meta); diags.HasError() {
+		t.Fatalf("unexpected error: %#v", diags)
+	}
+	expected := map[string]string{
+		"api":    `{"default":"security-policy"}`,
+		"web":    `{"default":"security-policy","ports":{"http":"web-http"}}`,
+		"custom": `{"default":"custom"}`,
+		"db":     "",
+	}
+	if actual := annotations(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected annotations %v, got %v", expected, actual)
+	}
+	if actual := services(d); !reflect.DeepEqual(actual, []string{"api", "web"}) {
+		t.Errorf("unexpected services %v", actual)
+	}
+	if d.Id() != "default/security-policy/tier=public" {
+		t.Errorf("unexpected ID %q", d.Id())
+	}
+
+	// A Service labelled after the apply is planned as drift.
+	if _, err := meta.clientset.CoreV1().Services("default").Create(ctx, service("admin", "public", nil).(*corev1.Service), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if diags := resourceServiceBackendConfigSelectorRead(ctx, d, meta); diags.HasError() {
+		t.Fatalf("unexpected error: %#v", diags)
+	}
+	diff, err := r.Diff(ctx, d.State(), config, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff == nil || diff.Attributes["services.#"] == nil || diff.Attributes["services.#"].New != "3" {
+		t.Fatalf("expected the new Service in the plan, got %#v", diff)
+	}
+	d, err = schema.InternalMap(r.Schema).Data(d.State(), diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diags := resourceServiceBackendConfigSelectorUpdate(ctx, d, meta); diags.HasError() {
+		t.Fatalf("unexpected error: %#v", diags)
+	}
+	if actual := services(d); !reflect.DeepEqual(actual, []string{"admin", "api", "web"}) {
+		t.Errorf("unexpected services %v", actual)
+	}
+
+	if diags := resourceServiceBackendConfigSelectorDelete(ctx, d, meta); diags.HasError() {
+		t.Fatalf("unexpected error: %#v", diags)
+	}
+	expected = map[string]string{
+		"admin":  "",
+		"api":    "",
+		"web":    `{"ports":{"http":"web-http"}}`,
+		"custom": `{"default":"custom"}`,
+		"db":     "",
+	}
+	if actual := annotations(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected annotations %v, got %v", expected, actual)
+	}
+}
+
+func TestServiceBackendConfigSelectorOverlap(t *testing.T) {
+	ctx := context.Background()
+	service := func(name string, labels map[string]string) *corev1.Service {
+		return &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels}}
+	}
+	meta := &apiClient{
+		namespace: "default",
+		clientset: fake.NewSimpleClientset(
+			service("api", map[string]string{"tier": "public"}),
+			service("web", map[string]string{"tier": "public", "app": "web"}),
+			service("admin", map[string]string{"tier": "internal"}),
+		),
+		dynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "cloud.google.com/v1",
+			"kind":       backendConfigKind,
+			"metadata":   map[string]interface{}{"name": "security-policy", "namespace": "default"},
+		}}),
+	}
+	defaultOf := func(name string) string {
+		svc, err := meta.clientset.CoreV1().Services("default").Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		configs, err := serviceBackendConfigAnnotation(*svc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return configs.Default
+	}
+	r := resourceServiceBackendConfigSelector()
+	selector := func(labels map[string]interface{}) *schema.ResourceData {
+		return schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+			"metadata":       []interface{}{map[string]interface{}{"namespace": "default"}},
+			"selector":       labels,
+			"backend_config": "security-policy",
+		})
+	}
+
+	public := selector(map[string]interface{}{"tier": "public"})
+	if diags := resourceServiceBackendConfigSelectorCreate(ctx, public, meta); diags.HasError() {
+		t.Fatalf("unexpected error: %#v", diags)
+	}
+
+	// A selector matching a Service annotated by another one is refused.
+	if diags := resourceServiceBackendConfigSelectorCreate(ctx, selector(map[string]interface{}{"app": "web"}), meta); !diags.HasError() {
+		t.Fatal("expected the overlapping selector to be refused")
+	}
+
+	// A Service relabelled to match both selectors is only reported by the one that did not
+	// annotate it, and left alone when that one is destroyed.
+	internal := selector(map[string]interface{}{"tier": "internal"})
+	if diags := resourceServiceBackendConfigSelectorCreate(ctx, internal, meta); diags.HasError() {
+		t.Fatalf("unexpected error: %#v", diags)
+	}
+	api := service("api", map[string]string{"tier": "internal"})
+	api.Annotations = map[string]string{backendConfigAnnotation: `{"default":"security-policy"}`}
+	if _, err := meta.clientset.CoreV1().Services("default").Update(ctx, api, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	diags := resourceServiceBackendConfigSelectorRead(ctx, internal, meta)
+	if len(diags) != 1 || diags[0].Summary != "Service annotated by another resource" {
+		t.Errorf("expected a warning for Service api, got %#v", diags)
+	}
+	if matching := internal.Get("matching_services").(*schema.Set); matching.Len() != 1 || !matching.Contains("admin") {
+		t.Errorf("unexpected matching services %v", matching.List())
+	}
+	if diags := resourceServiceBackendConfigSelectorDelete(ctx, internal, meta); diags.HasError() {
+		t.Fatalf("unexpected error: %#v", diags)
+	}
+	for name, expected := range map[string]string{"api": "security-policy", "web": "security-policy", "admin": ""} {
+		if actual := defaultOf(name); actual != expected {
+			t.Errorf("%s: expected default %q, got %q", name, expected, actual)
+		}
+	}
+}
+
+func TestServiceBackendConfigSelectorImport(t *testing.T) {
+	ctx := context.Background()
+	meta := &apiClient{
+		namespace: "default",
+		clientset: fake.NewSimpleClientset(
+			&corev1.Service{ObjectMeta: metav1.ObjectMeta{
+				Name:        "api",
+				Namespace:   "apps",
+				Labels:      map[string]string{"app.kubernetes.io/part-of": "shop", "tier": "public"},
+				Annotations: map[string]string{backendConfigAnnotation: `{"default":"security-policy"}`},
+			}},
+		),
+	}
+
+	r := resourceServiceBackendConfigSelector()
+	d := r.Data(&terraform.InstanceState{ID: "apps/security-policy/tier=public,app.kubernetes.io/part-of=shop"})
+	imported, err := r.Importer.StateContext(ctx, d, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d = imported[0]
+	if diags := r.ReadContext(ctx, d, meta); diags.HasError() {
+		t.Fatalf("unexpected error: %#v", diags)
+	}
+
+	if d.Id() != "apps/security-policy/app.kubernetes.io/part-of=shop,tier=public" {
+		t.Errorf("unexpected ID %q", d.Id())
+	}
+	expected := map[string]interface{}{"app.kubernetes.io/part-of": "shop", "tier": "public"}
+	if actual := d.Get("selector"); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected selector %v, got %v", expected, actual)
+	}
+	if d.Get("metadata.0.namespace") != "apps" || d.Get("backend_config") != "security-policy" {
+		t.Errorf("unexpected state %v", d.State().Attributes)
+	}
+	if services := d.Get("services").(*schema.Set); services.Len() != 1 || !services.Contains("api") {
+		t.Errorf("expected the annotated Service, got %v", services.List())
+	}
+
+	for _, id := range []string{"apps/security-policy", "apps/security-policy/", "apps/security-policy/tier"} {
+		if _, err := r.Importer.StateContext(ctx, r.Data(&terraform.InstanceState{ID: id}), meta); err == nil {
+			t.Errorf("%q: expected an error", id)
+		}
+	}
+}
+
+func TestServiceBackendConfigSelectorId(t *testing.T) {
+	public := buildServiceSelectorId("default", "security-policy", map[string]string{"tier": "public"})
+	internal := buildServiceSelectorId("default", "security-policy", map[string]string{"tier": "internal"})
+	if public == internal {
+		t.Errorf("expected distinct IDs for distinct selectors, got %q", public)
+	}
+}
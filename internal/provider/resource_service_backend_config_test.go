@@ -0,0 +1,171 @@
+package provider
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// testServiceAnnotationClient returns a client for a cluster with the Service svc
+// and the named backendconfigs in the default namespace.
+func testServiceAnnotationClient(svc *corev1.Service, backendConfigs ...string) *apiClient {
+	objects := make([]runtime.Object, 0, len(backendConfigs))
+	for _, name := range backendConfigs {
+		objects = append(objects, &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "cloud.google.com/v1",
+			"kind":       backendConfigKind,
+			"metadata":   map[string]interface{}{"name": name, "namespace": "default"},
+		}})
+	}
+	return &apiClient{
+		namespace:     "default",
+		clientset:     fake.NewSimpleClientset(svc),
+		dynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), objects...),
+	}
+}
+
+// testUnknownValue stands in a raw test configuration for a value only known at apply time.
+const testUnknownValue = "74D93920-ED26-11E3-AC10-0800200C9A66"
+
+func testService(annotations map[string]string) *corev1.Service {
+	return &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Annotations: annotations}}
+}
+
+func TestServiceBackendConfig(t *testing.T) {
+	ctx := context.Background()
+	meta := testServiceAnnotationClient(testService(map[string]string{
+		"example.com/owner":     "web",
+		backendConfigAnnotation: `{"ports": {"https": "web-tls", "8443": "web-tls"}, "default": "web"}`,
+	}), "web", "web-tls")
+
+	r := resourceServiceBackendConfig()
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"metadata": []interface{}{map[string]interface{}{"name": "web"}},
+		"default":  "web",
+		"ports":    map[string]interface{}{"https": "web-tls", "8443": "web-tls"},
+	})
+	if diags := resourceServiceBackendConfigCreate(ctx, d, meta); diags.HasError() {
+		t.Fatalf("unexpected error: %#v", diags)
+	}
+	if d.Id() != "default/web" {
+		t.Errorf("unexpected ID %q", d.Id())
+	}
+
+	svc, err := meta.clientset.CoreV1().Services("default").Get(ctx, "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"example.com/owner":     "web",
+		backendConfigAnnotation: `{"default":"web","ports":{"8443":"web-tls","https":"web-tls"}}`,
+	}
+	if !reflect.DeepEqual(svc.Annotations, expected) {
+		t.Errorf("expected annotations %v, got %v", expected, svc.Annotations)
+	}
+
+	if diags := resourceServiceBackendConfigDelete(ctx, d, meta); diags.HasError() {
+		t.Fatalf("unexpected error: %#v", diags)
+	}
+	svc, err = meta.clientset.CoreV1().Services("default").Get(ctx, "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = map[string]string{"example.com/owner": "web"}
+	if !reflect.DeepEqual(svc.Annotations, expected) {
+		t.Errorf("expected annotations %v, got %v", expected, svc.Annotations)
+	}
+}
+
+func TestServiceBackendConfigMissingBackendConfig(t *testing.T) {
+	meta := testServiceAnnotationClient(testService(nil), "web")
+
+	d := schema.TestResourceDataRaw(t, resourceServiceBackendConfig().Schema, map[string]interface{}{
+		"metadata": []interface{}{map[string]interface{}{"name": "web"}},
+		"ports":    map[string]interface{}{"http": "web", "https": "web-tls"},
+	})
+	diags := resourceServiceBackendConfigCreate(context.Background(), d, meta)
+	if !diags.HasError() || !strings.Contains(diags[0].Summary, `"web-tls" not found`) {
+		t.Fatalf("expected an error for the missing backend config, got %#v", diags)
+	}
+
+	svc, err := meta.clientset.CoreV1().Services("default").Get(context.Background(), "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := svc.Annotations[backendConfigAnnotation]; ok {
+		t.Error("annotation set despite the missing backend config")
+	}
+}
+
+func TestServiceBackendConfigForeignAnnotation(t *testing.T) {
+	ctx := context.Background()
+	foreign := `{"default": "legacy"}`
+	meta := testServiceAnnotationClient(testService(map[string]string{backendConfigAnnotation: foreign}), "web", "legacy")
+
+	d := schema.TestResourceDataRaw(t, resourceServiceBackendConfig().Schema, map[string]interface{}{
+		"metadata": []interface{}{map[string]interface{}{"name": "web"}},
+		"default":  "web",
+	})
+	diags := resourceServiceBackendConfigCreate(ctx, d, meta)
+	if !diags.HasError() || !strings.Contains(diags[0].Summary, "import it instead") {
+		t.Fatalf("expected the existing annotation to be refused, got %#v", diags)
+	}
+
+	svc, err := meta.clientset.CoreV1().Services("default").Get(ctx, "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if svc.Annotations[backendConfigAnnotation] != foreign {
+		t.Errorf("expected the annotation to be left alone, got %q", svc.Annotations[backendConfigAnnotation])
+	}
+}
+
+func TestServiceBackendConfigPlanMissingBackendConfig(t *testing.T) {
+	meta := testServiceAnnotationClient(testService(nil), "web")
+
+	cases := map[string]struct {
+		config      map[string]interface{}
+		errContains string
+	}{
+		"existing": {
+			config: map[string]interface{}{"default": "web"},
+		},
+		"missing default": {
+			config:      map[string]interface{}{"default": "web-legacy"},
+			errContains: `backend config "web-legacy" not found in namespace "default"`,
+		},
+		"missing port": {
+			config:      map[string]interface{}{"ports": map[string]interface{}{"http": "web", "https": "web-tls"}},
+			errContains: `backend config "web-tls" not found`,
+		},
+		"unknown": {
+			config: map[string]interface{}{"default": testUnknownValue},
+		},
+	}
+
+	for name, tc := range cases {
+		raw := map[string]interface{}{
+			"metadata": []interface{}{map[string]interface{}{"name": "web", "namespace": "default"}},
+		}
+		for k, v := range tc.config {
+			raw[k] = v
+		}
+		_, err := resourceServiceBackendConfig().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(raw), meta)
+		if tc.errContains == "" && err != nil {
+			t.Errorf("%s: unexpected error: %s", name, err)
+		}
+		if tc.errContains != "" && (err == nil || !strings.Contains(err.Error(), tc.errContains)) {
+			t.Errorf("%s: expected an error containing %q, got %v", name, tc.errContains, err)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func resourceServiceNEG() *schema.Resource {
+	return &schema.Resource{
+		Description:   "Manages the `cloud.google.com/neg` annotation of an existing Service, which enables container-native load balancing through network endpoint groups. The other annotations of the Service are left untouched. A Service whose annotation is already set to another value, such as one written by GKE, must be imported. On destroy, the annotation is restored to its value from before the resource managed it.",
+		CreateContext: resourceServiceNEGCreate,
+		ReadContext:   resourceServiceNEGRead,
+		UpdateContext: resourceServiceNEGUpdate,
+		DeleteContext: resourceServiceNEGDelete,
+		CustomizeDiff: resourceServiceAnnotationCustomizeDiff,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceServiceNEGImportState,
+		},
+		Schema: map[string]*schema.Schema{
+			"metadata": serviceMetadataSchema(),
+			"ingress": {
+				Type:         schema.TypeBool,
+				Description:  "Whether the GKE ingress controller uses network endpoint groups for the backends of the Service.",
+				Optional:     true,
+				AtLeastOneOf: []string{"ingress", "exposed_port"},
+			},
+			"exposed_port": {
+				Type:         schema.TypeSet,
+				Description:  "A Service port for which a standalone network endpoint group is created.",
+				Optional:     true,
+				AtLeastOneOf: []string{"ingress", "exposed_port"},
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"port": {
+							Type:         schema.TypeInt,
+							Description:  "Number of the Service port.",
+							Required:     true,
+							ValidateFunc: validatePortNum,
+						},
+						"name": {
+							Type:         schema.TypeString,
+							Description:  "Name of the network endpoint group. Generated by GKE when omitted.",
+							Optional:     true,
+							ValidateFunc: validateNEGName,
+						},
+					},
+				},
+			},
+			"initial_annotation": {
+				Type:        schema.TypeString,
+				Description: "Value of the annotation when the resource was created or imported, restored on destroy. Empty when the Service had none.",
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func resourceServiceNEGCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	namespace, name := expandServiceMetadata(d, meta)
+	if err := meta.(*apiClient).checkNamespaceAllowed(namespace); err != nil {
+		return diag.Errorf("metadata.0.namespace: %s", err)
+	}
+
+	initial, diags := applyServiceNEG(ctx, d, meta, namespace, name, true)
+	if diags.HasError() {
+		return diags
+	}
+	d.SetId(buildId(metav1.ObjectMeta{Namespace: namespace, Name: name}))
+	if err := d.Set("initial_annotation", initial); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return resourceServiceNEGRead(ctx, d, meta)
+}
+
+func resourceServiceNEGRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return refreshUnreachable(d, meta, err)
+	}
+
+	namespace, name, err := idParts(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if diags, stop := refreshTerminatingNamespace(ctx, d, meta, namespace); stop {
+		return diags
+	}
+
+	log.Printf("[INFO] Reading NEG annotation of service %s", d.Id())
+	svc, err := conn.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			log.Printf("[WARN] Service %s not found, removing from state", d.Id())
+			d.SetId("")
+			return nil
+		}
+		if isUnreachable(err) {
+			return refreshUnreachable(d, meta, err)
+		}
+		return diag.FromErr(err)
+	}
+
+	neg, err := serviceNEGAnnotation(*svc)
+	if err != nil {
+		return diag.Errorf("Invalid %s annotation on service %s: %s", negAnnotation, d.Id(), err)
+	}
+
+	attrs := map[string]interface{}{
+		"metadata":     flattenServiceMetadata(namespace, name),
+		"ingress":      neg.Ingress,
+		"exposed_port": flattenExposedPortsNEG(neg.ExposedPorts),
+	}
+	for k, v := range attrs {
+		if err := d.Set(k, v); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	return nil
+}
+
+func resourceServiceNEGUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	namespace, name, err := idParts(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if _, diags := applyServiceNEG(ctx, d, meta, namespace, name, false); diags.HasError() {
+		return diags
+	}
+
+	return resourceServiceNEGRead(ctx, d, meta)
+}
+
+func resourceServiceNEGDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	namespace, name, err := idParts(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	initial := d.Get("initial_annotation").(string)
+	log.Printf("[INFO] Restoring NEG annotation of service %s to %q", d.Id(), initial)
+	err = updateServiceAnnotation(ctx, conn, namespace, name, negAnnotation, func(string) (string, error) {
+		return initial, nil
+	})
+	if err != nil && !errors.IsNotFound(err) {
+		return diag.Errorf("Failed to restore NEG annotation of service %s: %s", d.Id(), err)
+	}
+
+	d.SetId("")
+	return nil
+}
+
+func resourceServiceNEGImportState(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	namespace, name, err := idParts(d.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	svc, err := conn.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service %s: %s", d.Id(), err)
+	}
+	if err := d.Set("initial_annotation", svc.Annotations[negAnnotation]); err != nil {
+		return nil, err
+	}
+
+	return []*schema.ResourceData{d}, nil
+}
+
+// applyServiceNEG writes the configured NEG annotation to the Service and returns the value
+// it replaced. On creation, it refuses to take over an annotation set to another value.
+func applyServiceNEG(ctx context.Context, d *schema.ResourceData, meta interface{}, namespace, name string, create bool) (string, diag.Diagnostics) {
+	conn, err := meta.(*apiClient).MainClientset()
+	if err != nil {
+		return "", diag.FromErr(err)
+	}
+
+	value, err := json.Marshal(expandServiceNEG(d))
+	if err != nil {
+		return "", diag.FromErr(err)
+	}
+
+	var previous string
+	log.Printf("[INFO] Setting NEG annotation of service %s/%s: %s", namespace, name, value)
+	err = updateServiceAnnotation(ctx, conn, namespace, name, negAnnotation, func(current string) (string, error) {
+		previous = current
+		if create && current != "" {
+			existing, err := parseServiceNEG(current)
+			if err != nil {
+				return "", fmt.Errorf("invalid %s annotation: %s", negAnnotation, err)
+			}
+			if v, _ := json.Marshal(existing); string(v) != string(value) {
+				return "", fmt.Errorf("the %s annotation is already set to %s, import it instead", negAnnotation, current)
+			}
+		}
+		return string(value), nil
+	})
+	if err != nil {
+		return "", diag.Errorf("Failed to set NEG annotation of service %s/%s: %s", namespace, name, err)
+	}
+	return previous, nil
+}
@@ -0,0 +1,164 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestServiceNEG(t *testing.T) {
+	ctx := context.Background()
+	meta := testServiceAnnotationClient(testService(map[string]string{"example.com/owner": "web"}))
+
+	r := resourceServiceNEG()
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"metadata": []interface{}{map[string]interface{}{"name": "web"}},
+		"ingress":  true,
+		"exposed_port": []interface{}{
+			map[string]interface{}{"port": 8080},
+			map[string]interface{}{"port": 443, "name": "web-tls"},
+		},
+	})
+	if diags := resourceServiceNEGCreate(ctx, d, meta); diags.HasError() {
+		t.Fatalf("unexpected error: %#v", diags)
+	}
+
+	svc, err := meta.clientset.CoreV1().Services("default").Get(ctx, "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"ingress":true,"exposed_ports":{"443":{"name":"web-tls"},"8080":{}}}`
+	if actual := svc.Annotations[negAnnotation]; actual != expected {
+		t.Errorf("expected annotation %s, got %s", expected, actual)
+	}
+	if d.Get("exposed_port").(*schema.Set).Len() != 2 {
+		t.Errorf("unexpected exposed ports %v", d.Get("exposed_port"))
+	}
+
+	if diags := resourceServiceNEGDelete(ctx, d, meta); diags.HasError() {
+		t.Fatalf("unexpected error: %#v", diags)
+	}
+	svc, err = meta.clientset.CoreV1().Services("default").Get(ctx, "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := svc.Annotations[negAnnotation]; ok || svc.Annotations["example.com/owner"] != "web" {
+		t.Errorf("expected only the NEG annotation to be removed, got %v", svc.Annotations)
+	}
+}
+
+func TestServiceNEGAnnotation(t *testing.T) {
+	cases := map[string]bool{
+		`{"ingress": true}`: true,
+		`{"exposed_ports": {"80": {}, "443": {"name": "a"}}}`: true,
+		`{"ingress": "true"}`:                     false,
+		`{"ingres": true}`:                        false,
+		`{"exposed_ports": {"http": {}}}`:         false,
+		`{"exposed_ports": {"70000": {}}}`:        false,
+		`{"exposed_ports": {"80": {"nam": "a"}}}`: false,
+	}
+
+	for value, valid := range cases {
+		_, err := serviceNEGAnnotation(*testService(map[string]string{negAnnotation: value}))
+		if valid && err != nil {
+			t.Errorf("%s: unexpected error: %s", value, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("%s: expected an error", value)
+		}
+	}
+}
+
+func TestServiceNEGExistingAnnotation(t *testing.T) {
+	ctx := context.Background()
+	gkeDefault := `{"ingress":true}`
+	r := resourceServiceNEG()
+	annotation := func(meta *apiClient) string {
+		svc, err := meta.clientset.CoreV1().Services("default").Get(ctx, "web", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return svc.Annotations[negAnnotation]
+	}
+
+	// A different value is refused and left alone.
+	meta := testServiceAnnotationClient(testService(map[string]string{negAnnotation: gkeDefault}))
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"metadata":     []interface{}{map[string]interface{}{"name": "web"}},
+		"exposed_port": []interface{}{map[string]interface{}{"port": 8080}},
+	})
+	diags := resourceServiceNEGCreate(ctx, d, meta)
+	if !diags.HasError() || !strings.Contains(diags[0].Summary, "import it instead") {
+		t.Fatalf("expected the existing annotation to be refused, got %#v", diags)
+	}
+	if actual := annotation(meta); actual != gkeDefault {
+		t.Errorf("expected the annotation to be left alone, got %s", actual)
+	}
+
+	// The same value is taken over and kept on destroy.
+	d = schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"metadata": []interface{}{map[string]interface{}{"name": "web"}},
+		"ingress":  true,
+	})
+	if diags := resourceServiceNEGCreate(ctx, d, meta); diags.HasError() {
+		t.Fatalf("unexpected error: %#v", diags)
+	}
+	if d.Get("initial_annotation") != gkeDefault {
+		t.Errorf("expected the initial annotation to be recorded, got %q", d.Get("initial_annotation"))
+	}
+	if diags := resourceServiceNEGDelete(ctx, d, meta); diags.HasError() {
+		t.Fatalf("unexpected error: %#v", diags)
+	}
+	if actual := annotation(meta); actual != gkeDefault {
+		t.Errorf("expected the annotation to be kept, got %s", actual)
+	}
+}
+
+func TestServiceNEGImport(t *testing.T) {
+	ctx := context.Background()
+	original := `{"exposed_ports": {"80": {}}}`
+	meta := testServiceAnnotationClient(testService(map[string]string{negAnnotation: original}))
+	r := resourceServiceNEG()
+
+	imported, err := r.Importer.StateContext(ctx, r.Data(&terraform.InstanceState{ID: "default/web"}), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diags := r.ReadContext(ctx, imported[0], meta); diags.HasError() {
+		t.Fatalf("unexpected error: %#v", diags)
+	}
+	state := imported[0].State()
+	if state.Attributes["initial_annotation"] != original {
+		t.Errorf("expected the annotation to be recorded on import, got %v", state.Attributes)
+	}
+
+	state, err = testApply(r, state, map[string]interface{}{
+		"metadata": []interface{}{map[string]interface{}{"name": "web"}},
+		"ingress":  true,
+	}, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := meta.clientset.CoreV1().Services("default").Get(ctx, "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := svc.Annotations[negAnnotation]; actual != `{"ingress":true}` {
+		t.Errorf("expected the configured annotation, got %s", actual)
+	}
+
+	if _, diags := r.Apply(ctx, state, &terraform.InstanceDiff{Destroy: true}, meta); diags.HasError() {
+		t.Fatalf("unexpected error: %#v", diags)
+	}
+	svc, err = meta.clientset.CoreV1().Services("default").Get(ctx, "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := svc.Annotations[negAnnotation]; actual != original {
+		t.Errorf("expected the original annotation to be restored, got %s", actual)
+	}
+}
@@ -93,10 +93,10 @@ func namespacedMetadataSchemaIsTemplate(objectName string, generatableName, isTe
 	fields := metadataFields(objectName)
 	fields["namespace"] = &schema.Schema{
 		Type:        schema.TypeString,
-		Description: fmt.Sprintf("Namespace defines the space within which name of the %s must be unique.", objectName),
+		Description: fmt.Sprintf("Namespace defines the space within which name of the %s must be unique. Defaults to the provider's `namespace`.", objectName),
 		Optional:    true,
 		ForceNew:    true,
-		Default:     conditionalDefault(!isTemplate, "default"),
+		Computed:    !isTemplate,
 	}
 	if generatableName {
 		fields["generate_name"] = &schema.Schema{
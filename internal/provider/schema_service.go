@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// serviceMetadataSchema identifies the existing Service whose annotations a resource
+// manages. The Service itself is neither created nor deleted.
+func serviceMetadataSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Description: "The existing Service to annotate. The Service itself is neither created nor deleted.",
+		Required:    true,
+		MaxItems:    1,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"name": {
+					Type:         schema.TypeString,
+					Description:  "Name of the Service.",
+					Required:     true,
+					ForceNew:     true,
+					ValidateFunc: validateName,
+				},
+				"namespace": {
+					Type:         schema.TypeString,
+					Description:  "Namespace of the Service. Defaults to the provider's `namespace`.",
+					Optional:     true,
+					Computed:     true,
+					ForceNew:     true,
+					ValidateFunc: validateNamespaceName,
+				},
+			},
+		},
+	}
+}
+
+// resourceServiceAnnotationCustomizeDiff fails the plan when the Service is in a namespace
+// the provider is not allowed to manage objects in.
+func resourceServiceAnnotationCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	return customizeDiffNamespace(d, meta)
+}
+
+// serviceSelectorMetadataSchema holds the namespace of the Services a resource selects.
+func serviceSelectorMetadataSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Description: "The namespace of the selected Services.",
+		Required:    true,
+		MaxItems:    1,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"namespace": {
+					Type:         schema.TypeString,
+					Description:  "Namespace of the Services and the backendconfig. Defaults to the provider's `namespace`.",
+					Optional:     true,
+					Computed:     true,
+					ForceNew:     true,
+					ValidateFunc: validateNamespaceName,
+				},
+			},
+		},
+	}
+}
+
+// expandServiceMetadata returns the namespace and name of the Service, the namespace
+// defaulting to the provider's.
+func expandServiceMetadata(d *schema.ResourceData, meta interface{}) (string, string) {
+	m := expandMetadata(d.Get("metadata").([]interface{}))
+	if m.Namespace == "" {
+		m.Namespace = meta.(*apiClient).namespace
+	}
+	return m.Namespace, m.Name
+}
+
+func flattenServiceMetadata(namespace, name string) []interface{} {
+	return []interface{}{map[string]interface{}{
+		"name":      name,
+		"namespace": namespace,
+	}}
+}
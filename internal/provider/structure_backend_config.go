@@ -0,0 +1,476 @@
+package provider
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// Expanders
+
+func expandBackendConfigSpec(l []interface{}) backendConfigSpec {
+	obj := backendConfigSpec{}
+	if len(l) == 0 || l[0] == nil {
+		return obj
+	}
+	in := l[0].(map[string]interface{})
+
+	if v, ok := in["timeout_sec"].(int); ok && v > 0 {
+		obj.TimeoutSec = ptrToInt64(int64(v))
+	}
+	if v, ok := in["cdn"].([]interface{}); ok && len(v) > 0 {
+		obj.Cdn = expandBackendConfigCdn(v)
+	}
+	if v, ok := in["connection_draining"].([]interface{}); ok && len(v) > 0 {
+		obj.ConnectionDraining = expandBackendConfigConnectionDraining(v)
+	}
+	if v, ok := in["health_check"].([]interface{}); ok && len(v) > 0 {
+		obj.HealthCheck = expandBackendConfigHealthCheck(v)
+	}
+	if v, ok := in["security_policy"].([]interface{}); ok && len(v) > 0 {
+		obj.SecurityPolicy = expandBackendConfigSecurityPolicy(v)
+	}
+	if v, ok := in["logging"].([]interface{}); ok && len(v) > 0 {
+		obj.Logging = expandBackendConfigLogging(v)
+	}
+	if v, ok := in["iap"].([]interface{}); ok && len(v) > 0 {
+		obj.Iap = expandBackendConfigIap(v)
+	}
+	if v, ok := in["session_affinity"].([]interface{}); ok && len(v) > 0 {
+		obj.SessionAffinity = expandBackendConfigSessionAffinity(v)
+	}
+	if v, ok := in["custom_request_headers"].([]interface{}); ok && len(v) > 0 {
+		obj.CustomRequestHeaders = expandBackendConfigCustomRequestHeaders(v)
+	}
+	if v, ok := in["custom_response_headers"].([]interface{}); ok && len(v) > 0 {
+		obj.CustomResponseHeaders = expandBackendConfigCustomResponseHeaders(v)
+	}
+
+	return obj
+}
+
+func expandBackendConfigCdn(l []interface{}) *cdnConfig {
+	obj := &cdnConfig{}
+	if l[0] == nil {
+		return obj
+	}
+	in := l[0].(map[string]interface{})
+
+	if v, ok := in["enabled"].(bool); ok {
+		obj.Enabled = v
+	}
+	if v, ok := in["cache_policy"].([]interface{}); ok && len(v) > 0 {
+		obj.CachePolicy = expandBackendConfigCachePolicy(v)
+	}
+	if v, ok := in["signed_url_cache_max_age_sec"].(string); ok && v != "" {
+		i, _ := strconv.ParseInt(v, 10, 64)
+		obj.SignedURLCacheMaxAgeSec = ptrToInt64(i)
+	}
+	if v, ok := in["signed_url_keys"].([]interface{}); ok && len(v) > 0 {
+		obj.SignedURLKeys = expandBackendConfigSignedURLKeys(v)
+	}
+
+	return obj
+}
+
+func expandBackendConfigCachePolicy(l []interface{}) *cacheKeyPolicy {
+	obj := &cacheKeyPolicy{}
+	if l[0] == nil {
+		// An empty cache_policy block is still sent, so that it reads back as a block.
+		return obj
+	}
+	in := l[0].(map[string]interface{})
+
+	if v, ok := in["include_host"].(bool); ok {
+		obj.IncludeHost = v
+	}
+	if v, ok := in["include_protocol"].(bool); ok {
+		obj.IncludeProtocol = v
+	}
+	if v, ok := in["include_query_string"].(bool); ok {
+		obj.IncludeQueryString = v
+	}
+	if v, ok := in["query_string_blacklist"].(*schema.Set); ok && v.Len() > 0 {
+		obj.QueryStringBlacklist = sliceOfString(v.List())
+	}
+	if v, ok := in["query_string_whitelist"].(*schema.Set); ok && v.Len() > 0 {
+		obj.QueryStringWhitelist = sliceOfString(v.List())
+	}
+
+	return obj
+}
+
+func expandBackendConfigSignedURLKeys(l []interface{}) []*signedURLKeyRef {
+	obj := make([]*signedURLKeyRef, 0, len(l))
+	for _, k := range l {
+		if k == nil {
+			continue
+		}
+		in := k.(map[string]interface{})
+		obj = append(obj, &signedURLKeyRef{
+			KeyName:    in["key_name"].(string),
+			SecretName: in["secret_name"].(string),
+		})
+	}
+	return obj
+}
+
+func expandBackendConfigConnectionDraining(l []interface{}) *connectionDrainingConfig {
+	obj := &connectionDrainingConfig{}
+	if l[0] == nil {
+		return obj
+	}
+	in := l[0].(map[string]interface{})
+
+	if v, ok := in["draining_timeout_sec"].(int); ok {
+		obj.DrainingTimeoutSec = int64(v)
+	}
+
+	return obj
+}
+
+func expandBackendConfigHealthCheck(l []interface{}) *healthCheckConfig {
+	obj := &healthCheckConfig{}
+	if l[0] == nil {
+		return obj
+	}
+	in := l[0].(map[string]interface{})
+
+	if v, ok := in["check_interval_sec"].(int); ok && v > 0 {
+		obj.CheckIntervalSec = ptrToInt64(int64(v))
+	}
+	if v, ok := in["timeout_sec"].(int); ok && v > 0 {
+		obj.TimeoutSec = ptrToInt64(int64(v))
+	}
+	if v, ok := in["healthy_threshold"].(int); ok && v > 0 {
+		obj.HealthyThreshold = ptrToInt64(int64(v))
+	}
+	if v, ok := in["unhealthy_threshold"].(int); ok && v > 0 {
+		obj.UnhealthyThreshold = ptrToInt64(int64(v))
+	}
+	if v, ok := in["type"].(string); ok && v != "" {
+		obj.Type = ptrToString(canonicalEnumValue(v, healthCheckTypes))
+	}
+	if v, ok := in["request_path"].(string); ok && v != "" {
+		obj.RequestPath = ptrToString(v)
+	}
+	if v, ok := in["port"].(int); ok && v > 0 {
+		obj.Port = ptrToInt64(int64(v))
+	}
+
+	return obj
+}
+
+func expandBackendConfigSecurityPolicy(l []interface{}) *securityPolicyConfig {
+	obj := &securityPolicyConfig{}
+	if l[0] == nil {
+		return obj
+	}
+	in := l[0].(map[string]interface{})
+
+	if v, ok := in["name"].(string); ok {
+		obj.Name = v
+	}
+
+	return obj
+}
+
+func expandBackendConfigLogging(l []interface{}) *logConfig {
+	obj := &logConfig{}
+	if l[0] == nil {
+		return obj
+	}
+	in := l[0].(map[string]interface{})
+
+	if v, ok := in["enable"].(bool); ok {
+		obj.Enable = v
+	}
+	if v, ok := in["sample_rate"].(string); ok && v != "" {
+		f, _ := strconv.ParseFloat(v, 64)
+		obj.SampleRate = ptrToFloat64(f)
+	}
+
+	return obj
+}
+
+func expandBackendConfigIap(l []interface{}) *iapConfig {
+	obj := &iapConfig{}
+	if l[0] == nil {
+		return obj
+	}
+	in := l[0].(map[string]interface{})
+
+	if v, ok := in["enabled"].(bool); ok {
+		obj.Enabled = v
+	}
+	if v, ok := in["oauthclient_credentials_secret_name"].(string); ok && v != "" {
+		obj.OAuthClientCredentials = &oauthClientCredentials{SecretName: v}
+	}
+
+	return obj
+}
+
+func expandBackendConfigSessionAffinity(l []interface{}) *sessionAffinityConfig {
+	obj := &sessionAffinityConfig{}
+	if l[0] == nil {
+		return obj
+	}
+	in := l[0].(map[string]interface{})
+
+	if v, ok := in["affinity_type"].(string); ok {
+		obj.AffinityType = canonicalEnumValue(v, sessionAffinityTypes)
+	}
+	if v, ok := in["affinity_cookie_ttl_sec"].(string); ok && v != "" {
+		i, _ := strconv.ParseInt(v, 10, 64)
+		obj.AffinityCookieTtlSec = ptrToInt64(i)
+	}
+
+	return obj
+}
+
+func expandBackendConfigCustomRequestHeaders(l []interface{}) *customRequestHeadersConfig {
+	obj := &customRequestHeadersConfig{}
+	if l[0] == nil {
+		return obj
+	}
+	in := l[0].(map[string]interface{})
+
+	if v, ok := in["headers"].(*schema.Set); ok && v.Len() > 0 {
+		obj.Headers = sliceOfString(v.List())
+	}
+	if v, ok := in["header"].([]interface{}); ok && len(v) > 0 {
+		for _, h := range v {
+			if h == nil {
+				continue
+			}
+			header := h.(map[string]interface{})
+			obj.Headers = append(obj.Headers, header["name"].(string)+":"+header["value"].(string))
+		}
+	}
+
+	return obj
+}
+
+func expandBackendConfigCustomResponseHeaders(l []interface{}) *customResponseHeadersConfig {
+	obj := &customResponseHeadersConfig{}
+	if l[0] == nil {
+		return obj
+	}
+	in := l[0].(map[string]interface{})
+
+	if v, ok := in["headers"].(*schema.Set); ok && v.Len() > 0 {
+		obj.Headers = sliceOfString(v.List())
+	}
+
+	return obj
+}
+
+// Flatteners
+
+func flattenBackendConfigSpec(in backendConfigSpec, d *schema.ResourceData) []interface{} {
+	att := make(map[string]interface{})
+
+	if in.TimeoutSec != nil {
+		att["timeout_sec"] = int(*in.TimeoutSec)
+	}
+	if in.Cdn != nil {
+		att["cdn"] = flattenBackendConfigCdn(in.Cdn)
+	}
+	if in.ConnectionDraining != nil {
+		att["connection_draining"] = flattenBackendConfigConnectionDraining(in.ConnectionDraining)
+	}
+	if in.HealthCheck != nil {
+		att["health_check"] = flattenBackendConfigHealthCheck(in.HealthCheck)
+	}
+	if in.SecurityPolicy != nil {
+		att["security_policy"] = flattenBackendConfigSecurityPolicy(in.SecurityPolicy)
+	}
+	if in.Logging != nil {
+		att["logging"] = flattenBackendConfigLogging(in.Logging)
+	}
+	if in.Iap != nil {
+		att["iap"] = flattenBackendConfigIap(in.Iap, d)
+	}
+	if in.SessionAffinity != nil {
+		att["session_affinity"] = flattenBackendConfigSessionAffinity(in.SessionAffinity)
+	}
+	if in.CustomRequestHeaders != nil {
+		structured := false
+		if d != nil {
+			structured = len(d.Get("spec.0.custom_request_headers.0.header").([]interface{})) > 0
+		}
+		att["custom_request_headers"] = flattenBackendConfigCustomRequestHeaders(in.CustomRequestHeaders, structured)
+	}
+	if in.CustomResponseHeaders != nil {
+		att["custom_response_headers"] = flattenBackendConfigCustomResponseHeaders(in.CustomResponseHeaders)
+	}
+
+	return []interface{}{att}
+}
+
+func flattenBackendConfigCdn(in *cdnConfig) []interface{} {
+	att := make(map[string]interface{})
+
+	att["enabled"] = in.Enabled
+	if in.CachePolicy != nil {
+		att["cache_policy"] = flattenBackendConfigCachePolicy(in.CachePolicy)
+	}
+	if in.SignedURLCacheMaxAgeSec != nil {
+		att["signed_url_cache_max_age_sec"] = strconv.FormatInt(*in.SignedURLCacheMaxAgeSec, 10)
+	}
+	if len(in.SignedURLKeys) > 0 {
+		att["signed_url_keys"] = flattenBackendConfigSignedURLKeys(in.SignedURLKeys)
+	}
+
+	return []interface{}{att}
+}
+
+func flattenBackendConfigCachePolicy(in *cacheKeyPolicy) []interface{} {
+	att := make(map[string]interface{})
+
+	att["include_host"] = in.IncludeHost
+	att["include_protocol"] = in.IncludeProtocol
+	att["include_query_string"] = in.IncludeQueryString
+	if len(in.QueryStringBlacklist) > 0 {
+		att["query_string_blacklist"] = newStringSet(schema.HashString, in.QueryStringBlacklist)
+	}
+	if len(in.QueryStringWhitelist) > 0 {
+		att["query_string_whitelist"] = newStringSet(schema.HashString, in.QueryStringWhitelist)
+	}
+
+	return []interface{}{att}
+}
+
+func flattenBackendConfigSignedURLKeys(in []*signedURLKeyRef) []interface{} {
+	att := make([]interface{}, 0, len(in))
+	for _, k := range in {
+		if k == nil {
+			continue
+		}
+		att = append(att, map[string]interface{}{
+			"key_name":    k.KeyName,
+			"secret_name": k.SecretName,
+		})
+	}
+	return att
+}
+
+func flattenBackendConfigConnectionDraining(in *connectionDrainingConfig) []interface{} {
+	att := make(map[string]interface{})
+
+	att["draining_timeout_sec"] = int(in.DrainingTimeoutSec)
+
+	return []interface{}{att}
+}
+
+func flattenBackendConfigHealthCheck(in *healthCheckConfig) []interface{} {
+	att := make(map[string]interface{})
+
+	if in.CheckIntervalSec != nil {
+		att["check_interval_sec"] = int(*in.CheckIntervalSec)
+	}
+	if in.TimeoutSec != nil {
+		att["timeout_sec"] = int(*in.TimeoutSec)
+	}
+	if in.HealthyThreshold != nil {
+		att["healthy_threshold"] = int(*in.HealthyThreshold)
+	}
+	if in.UnhealthyThreshold != nil {
+		att["unhealthy_threshold"] = int(*in.UnhealthyThreshold)
+	}
+	if in.Type != nil {
+		att["type"] = canonicalEnumValue(*in.Type, healthCheckTypes)
+	}
+	if in.RequestPath != nil {
+		att["request_path"] = *in.RequestPath
+	}
+	if in.Port != nil {
+		att["port"] = int(*in.Port)
+	}
+
+	return []interface{}{att}
+}
+
+func flattenBackendConfigSecurityPolicy(in *securityPolicyConfig) []interface{} {
+	att := make(map[string]interface{})
+
+	att["name"] = in.Name
+
+	return []interface{}{att}
+}
+
+func flattenBackendConfigLogging(in *logConfig) []interface{} {
+	att := make(map[string]interface{})
+
+	att["enable"] = in.Enable
+	if in.SampleRate != nil {
+		att["sample_rate"] = strconv.FormatFloat(*in.SampleRate, 'f', -1, 64)
+	}
+
+	return []interface{}{att}
+}
+
+func flattenBackendConfigIap(in *iapConfig, d *schema.ResourceData) []interface{} {
+	att := make(map[string]interface{})
+
+	att["enabled"] = in.Enabled
+	if in.OAuthClientCredentials != nil {
+		att["oauthclient_credentials_secret_name"] = in.OAuthClientCredentials.SecretName
+	}
+	if d != nil {
+		// The OAuth client only lives in the Secret, keep the configured values.
+		att["oauth_client_id"] = d.Get("spec.0.iap.0.oauth_client_id")
+		att["oauth_client_secret"] = d.Get("spec.0.iap.0.oauth_client_secret")
+	}
+
+	return []interface{}{att}
+}
+
+func flattenBackendConfigSessionAffinity(in *sessionAffinityConfig) []interface{} {
+	att := make(map[string]interface{})
+
+	att["affinity_type"] = canonicalEnumValue(in.AffinityType, sessionAffinityTypes)
+	if in.AffinityCookieTtlSec != nil {
+		att["affinity_cookie_ttl_sec"] = strconv.FormatInt(*in.AffinityCookieTtlSec, 10)
+	}
+
+	return []interface{}{att}
+}
+
+// flattenBackendConfigCustomRequestHeaders keeps the form used in the configuration, as
+// both `headers` and `header` are rendered to the same list of "Name:value" strings.
+func flattenBackendConfigCustomRequestHeaders(in *customRequestHeadersConfig, structured bool) []interface{} {
+	att := make(map[string]interface{})
+
+	if len(in.Headers) == 0 {
+		return []interface{}{att}
+	}
+	if !structured {
+		att["headers"] = newStringSet(schema.HashString, in.Headers)
+		return []interface{}{att}
+	}
+
+	headers := make([]interface{}, 0, len(in.Headers))
+	for _, h := range in.Headers {
+		parts := strings.SplitN(h, ":", 2)
+		header := map[string]interface{}{"name": parts[0], "value": ""}
+		if len(parts) == 2 {
+			header["value"] = parts[1]
+		}
+		headers = append(headers, header)
+	}
+	att["header"] = headers
+
+	return []interface{}{att}
+}
+
+func flattenBackendConfigCustomResponseHeaders(in *customResponseHeadersConfig) []interface{} {
+	att := make(map[string]interface{})
+
+	if len(in.Headers) > 0 {
+		att["headers"] = newStringSet(schema.HashString, in.Headers)
+	}
+
+	return []interface{}{att}
+}
@@ -0,0 +1,118 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestBackendConfigSpecRoundTrip applies every configuration in testdata/roundtrip, sends the
+// resulting object through the JSON encoding used by the API server and checks that planning
+// the same configuration again shows no changes.
+func TestBackendConfigSpecRoundTrip(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "roundtrip", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("no round-trip fixtures found")
+	}
+
+	meta := &apiClient{
+		clientset: fake.NewSimpleClientset(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "iap-oauth", Namespace: "default"},
+			Data: map[string][]byte{
+				iapOAuthClientIDKey:     []byte("1234.apps.googleusercontent.com"),
+				iapOAuthClientSecretKey: []byte("s3cr3t"),
+			},
+		}),
+		defaultLabels:      map[string]string{"team": "platform", "cost-center": "1234"},
+		defaultAnnotations: map[string]string{"example.com/managed-by": "terraform"},
+	}
+
+	for _, fixture := range fixtures {
+		fixture := fixture
+		t.Run(filepath.Base(fixture), func(t *testing.T) {
+			ctx := context.Background()
+			r := resourceBackendConfig()
+
+			b, err := ioutil.ReadFile(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw := make(map[string]interface{})
+			if err := json.Unmarshal(b, &raw); err != nil {
+				t.Fatal(err)
+			}
+			config := terraform.NewResourceConfigRaw(raw)
+
+			if diags := r.Validate(config); diags.HasError() {
+				t.Fatalf("invalid fixture: %#v", diags)
+			}
+
+			planned, err := r.Diff(ctx, nil, config, meta)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, err := schema.InternalMap(r.Schema).Data(nil, planned)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			in := backendConfig{
+				ObjectMeta: expandEffectiveMetadata(d, meta),
+				Spec:       expandBackendConfigSpec(d.Get("spec").([]interface{})),
+			}
+			out := roundTripThroughAPI(t, in)
+
+			d.SetId(buildId(out.ObjectMeta))
+			if err := setEffectiveMetadata(d, meta, out.ObjectMeta); err != nil {
+				t.Fatal(err)
+			}
+			if err := d.Set("spec", flattenBackendConfigSpec(out.Spec, d)); err != nil {
+				t.Fatal(err)
+			}
+			if err := d.Set("referenced_by", flattenBackendConfigReferences(nil)); err != nil {
+				t.Fatal(err)
+			}
+
+			diff, err := r.SimpleDiff(ctx, d.State(), config, meta)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, attr := range diff.Attributes {
+				if attr.NewComputed || attr.NewRemoved || attr.Old != attr.New {
+					t.Errorf("unexpected diff for %s: %q => %q (computed: %t, removed: %t)", k, attr.Old, attr.New, attr.NewComputed, attr.NewRemoved)
+				}
+			}
+		})
+	}
+}
+
+func roundTripThroughAPI(t *testing.T, in backendConfig) backendConfig {
+	if in.Namespace == "" {
+		in.Namespace = "default"
+	}
+	obj, err := backendConfigToUnstructured(&in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := obj.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := backendConfig{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
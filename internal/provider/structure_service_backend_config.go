@@ -0,0 +1,147 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Service annotations through which the GKE ingress controller attaches backendconfigs to the
+// ports of a Service. The beta annotation is still honored when the GA one is absent.
+const (
+	backendConfigAnnotation     = "cloud.google.com/backend-config"
+	betaBackendConfigAnnotation = "beta.cloud.google.com/backend-config"
+)
+
+// serviceBackendConfigs is the value of the backend-config annotation, for example
+// {"default": "web", "ports": {"http": "web-http", "8443": "web-tls"}}.
+type serviceBackendConfigs struct {
+	Default string            `json:"default,omitempty"`
+	Ports   map[string]string `json:"ports,omitempty"`
+}
+
+// equal tells whether c and other attach the same backendconfigs.
+func (c serviceBackendConfigs) equal(other serviceBackendConfigs) bool {
+	if c.Default != other.Default || len(c.Ports) != len(other.Ports) {
+		return false
+	}
+	for port, name := range c.Ports {
+		if other.Ports[port] != name {
+			return false
+		}
+	}
+	return true
+}
+
+// backendConfigReference is a Service port using a backendconfig, either as the default of
+// the Service or for that port specifically.
+type backendConfigReference struct {
+	Service    string
+	Port       string
+	Default    bool
+	Annotation string
+}
+
+// expandServiceBackendConfigs parses the backend-config annotation of a Service, preferring
+// the GA annotation over the beta one. It returns the annotation used, empty when the
+// Service has none.
+func expandServiceBackendConfigs(annotations map[string]string) (serviceBackendConfigs, string, error) {
+	out := serviceBackendConfigs{}
+	for _, k := range []string{backendConfigAnnotation, betaBackendConfigAnnotation} {
+		v, ok := annotations[k]
+		if !ok {
+			continue
+		}
+		if err := json.Unmarshal([]byte(v), &out); err != nil {
+			return out, k, err
+		}
+		return out, k, nil
+	}
+	return out, "", nil
+}
+
+// serviceBackendConfigAnnotation parses the GA backend-config annotation of svc, the one
+// written by the provider. It is empty when the Service has none.
+func serviceBackendConfigAnnotation(svc corev1.Service) (serviceBackendConfigs, error) {
+	configs := serviceBackendConfigs{}
+	v, ok := svc.Annotations[backendConfigAnnotation]
+	if !ok {
+		return configs, nil
+	}
+	err := json.Unmarshal([]byte(v), &configs)
+	return configs, err
+}
+
+// backendConfigReferences returns the references to the named backendconfig in the
+// backend-config annotation of svc. A default reference has an empty Port.
+func backendConfigReferences(svc corev1.Service, name string) ([]backendConfigReference, error) {
+	configs, annotation, err := expandServiceBackendConfigs(svc.Annotations)
+	if err != nil || annotation == "" {
+		return nil, err
+	}
+
+	var refs []backendConfigReference
+	if configs.Default == name {
+		refs = append(refs, backendConfigReference{Service: svc.Name, Default: true, Annotation: annotation})
+	}
+	ports := make([]string, 0, len(configs.Ports))
+	for port, bc := range configs.Ports {
+		if bc == name {
+			ports = append(ports, port)
+		}
+	}
+	sort.Strings(ports)
+	for _, port := range ports {
+		refs = append(refs, backendConfigReference{Service: svc.Name, Port: port, Annotation: annotation})
+	}
+	return refs, nil
+}
+
+// patchServiceBackendConfigs returns the backend-config annotation value modified by f. The
+// value is empty once neither a default nor a port is left.
+func patchServiceBackendConfigs(value string, f func(configs *serviceBackendConfigs)) (string, error) {
+	configs := serviceBackendConfigs{}
+	if value != "" {
+		if err := json.Unmarshal([]byte(value), &configs); err != nil {
+			return "", fmt.Errorf("invalid %s annotation: %s", backendConfigAnnotation, err)
+		}
+	}
+
+	f(&configs)
+	if configs.Default == "" && len(configs.Ports) == 0 {
+		return "", nil
+	}
+
+	b, err := json.Marshal(configs)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func flattenBackendConfigReferences(in []backendConfigReference) []interface{} {
+	att := make([]interface{}, 0, len(in))
+	for _, r := range in {
+		att = append(att, map[string]interface{}{
+			"service": r.Service,
+			"port":    r.Port,
+			"default": r.Default,
+		})
+	}
+	return att
+}
+
+func expandBackendConfigReferences(in []interface{}) []backendConfigReference {
+	out := make([]backendConfigReference, 0, len(in))
+	for _, v := range in {
+		m := v.(map[string]interface{})
+		out = append(out, backendConfigReference{
+			Service: m["service"].(string),
+			Port:    m["port"].(string),
+			Default: m["default"].(bool),
+		})
+	}
+	return out
+}
@@ -0,0 +1,87 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	corev1 "k8s.io/api/core/v1"
+	utilValidation "k8s.io/apimachinery/pkg/util/validation"
+)
+
+// negAnnotation enables container-native load balancing of a Service through network
+// endpoint groups, for example {"ingress": true, "exposed_ports": {"80": {"name": "web"}}}.
+const negAnnotation = "cloud.google.com/neg"
+
+// serviceNEG is the value of the NEG annotation.
+type serviceNEG struct {
+	Ingress      bool                      `json:"ingress,omitempty"`
+	ExposedPorts map[string]exposedPortNEG `json:"exposed_ports,omitempty"`
+}
+
+type exposedPortNEG struct {
+	Name string `json:"name,omitempty"`
+}
+
+// serviceNEGAnnotation parses the NEG annotation of svc, rejecting unknown fields and
+// exposed ports that are not port numbers. It is empty when the Service has none.
+func serviceNEGAnnotation(svc corev1.Service) (serviceNEG, error) {
+	v, ok := svc.Annotations[negAnnotation]
+	if !ok {
+		return serviceNEG{}, nil
+	}
+	return parseServiceNEG(v)
+}
+
+// parseServiceNEG parses a value of the NEG annotation, as serviceNEGAnnotation.
+func parseServiceNEG(v string) (serviceNEG, error) {
+	out := serviceNEG{}
+	dec := json.NewDecoder(bytes.NewReader([]byte(v)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&out); err != nil {
+		return out, err
+	}
+	for port := range out.ExposedPorts {
+		n, err := strconv.Atoi(port)
+		if err != nil {
+			return out, fmt.Errorf("exposed port %q is not a port number", port)
+		}
+		if errs := utilValidation.IsValidPortNum(n); len(errs) > 0 {
+			return out, fmt.Errorf("exposed port %q %s", port, errs[0])
+		}
+	}
+	return out, nil
+}
+
+func expandServiceNEG(d *schema.ResourceData) serviceNEG {
+	out := serviceNEG{Ingress: d.Get("ingress").(bool)}
+	for _, v := range d.Get("exposed_port").(*schema.Set).List() {
+		m := v.(map[string]interface{})
+		if out.ExposedPorts == nil {
+			out.ExposedPorts = make(map[string]exposedPortNEG)
+		}
+		out.ExposedPorts[strconv.Itoa(m["port"].(int))] = exposedPortNEG{Name: m["name"].(string)}
+	}
+	return out
+}
+
+func flattenExposedPortsNEG(in map[string]exposedPortNEG) []interface{} {
+	ports := make([]string, 0, len(in))
+	for port := range in {
+		ports = append(ports, port)
+	}
+	sort.Strings(ports)
+
+	att := make([]interface{}, 0, len(in))
+	for _, port := range ports {
+		n, _ := strconv.Atoi(port)
+		att = append(att, map[string]interface{}{
+			"port": n,
+			"name": in[port].Name,
+		})
+	}
+	return att
+}
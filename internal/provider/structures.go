@@ -0,0 +1,293 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// Stolen from https://github.com/hashicorp/terraform-provider-kubernetes/blob/master/kubernetes/structures.go
+
+func idParts(id string) (string, string, error) {
+	parts := strings.Split(id, "/")
+	if len(parts) != 2 {
+		err := fmt.Errorf("unexpected ID format (%q), expected %q.", id, "namespace/name")
+		return "", "", err
+	}
+
+	return parts[0], parts[1], nil
+}
+
+func buildId(meta metav1.ObjectMeta) string {
+	return meta.Namespace + "/" + meta.Name
+}
+
+func expandMetadata(in []interface{}) metav1.ObjectMeta {
+	meta := metav1.ObjectMeta{}
+	if len(in) < 1 {
+		return meta
+	}
+	m := in[0].(map[string]interface{})
+
+	if v, ok := m["annotations"].(map[string]interface{}); ok && len(v) > 0 {
+		meta.Annotations = expandStringMap(m["annotations"].(map[string]interface{}))
+	}
+
+	if v, ok := m["labels"].(map[string]interface{}); ok && len(v) > 0 {
+		meta.Labels = expandStringMap(m["labels"].(map[string]interface{}))
+	}
+
+	if v, ok := m["generate_name"]; ok {
+		meta.GenerateName = v.(string)
+	}
+	if v, ok := m["name"]; ok {
+		meta.Name = v.(string)
+	}
+	if v, ok := m["namespace"]; ok {
+		meta.Namespace = v.(string)
+	}
+
+	return meta
+}
+
+func expandStringMap(m map[string]interface{}) map[string]string {
+	result := make(map[string]string)
+	for k, v := range m {
+		result[k] = v.(string)
+	}
+	return result
+}
+
+func expandStringSlice(s []interface{}) []string {
+	result := make([]string, len(s))
+	for k, v := range s {
+		// Handle the Terraform parser bug which turns empty strings in lists to nil.
+		if v == nil {
+			result[k] = ""
+		} else {
+			result[k] = v.(string)
+		}
+	}
+	return result
+}
+
+func flattenMetadata(meta metav1.ObjectMeta, d *schema.ResourceData) []interface{} {
+	m := make(map[string]interface{})
+	configAnnotations := d.Get("metadata.0.annotations").(map[string]interface{})
+	m["annotations"] = removeInternalKeys(meta.Annotations, configAnnotations)
+	if meta.GenerateName != "" {
+		m["generate_name"] = meta.GenerateName
+	}
+
+	configLabels := d.Get("metadata.0.labels").(map[string]interface{})
+	m["labels"] = removeInternalKeys(meta.Labels, configLabels)
+	m["name"] = meta.Name
+	m["resource_version"] = meta.ResourceVersion
+	m["self_link"] = meta.SelfLink
+	m["uid"] = fmt.Sprintf("%v", meta.UID)
+	m["generation"] = meta.Generation
+
+	if meta.Namespace != "" {
+		m["namespace"] = meta.Namespace
+	}
+
+	return []interface{}{m}
+}
+
+func removeInternalKeys(m map[string]string, d map[string]interface{}) map[string]string {
+	for k := range m {
+		if isInternalKey(k) && !isKeyInMap(k, d) {
+			delete(m, k)
+		}
+	}
+	return m
+}
+
+func isKeyInMap(key string, d map[string]interface{}) bool {
+	if d == nil {
+		return false
+	}
+	for k := range d {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func isInternalKey(annotationKey string) bool {
+	u, err := url.Parse("//" + annotationKey)
+	if err == nil && strings.HasSuffix(u.Hostname(), "kubernetes.io") {
+		return true
+	}
+
+	return false
+}
+
+func newStringSet(f schema.SchemaSetFunc, in []string) *schema.Set {
+	var out = make([]interface{}, len(in))
+	for i, v := range in {
+		out[i] = v
+	}
+	return schema.NewSet(f, out)
+}
+
+func sliceOfString(slice []interface{}) []string {
+	result := make([]string, len(slice))
+	for i, s := range slice {
+		result[i] = s.(string)
+	}
+	return result
+}
+
+func ptrToString(s string) *string {
+	return &s
+}
+
+func ptrToInt64(i int64) *int64 {
+	return &i
+}
+
+func ptrToFloat64(f float64) *float64 {
+	return &f
+}
+
+// patchStringMap applies the difference between the old and new configured values
+// to the live map, leaving keys that were never configured untouched.
+func patchStringMap(live map[string]string, o, n map[string]interface{}) map[string]string {
+	if live == nil {
+		live = make(map[string]string)
+	}
+	for k := range o {
+		if _, ok := n[k]; !ok {
+			delete(live, k)
+		}
+	}
+	for k, v := range n {
+		live[k] = v.(string)
+	}
+	if len(live) == 0 {
+		return nil
+	}
+	return live
+}
+
+// canonicalEnumValue returns the valid value matching v regardless of case, or v itself
+// when it matches none of them.
+func canonicalEnumValue(v string, validValues []string) string {
+	for _, s := range validValues {
+		if strings.EqualFold(s, v) {
+			return s
+		}
+	}
+	return v
+}
+
+// expandRegexpList compiles a list of patterns already checked with validateRegexp.
+func expandRegexpList(in []interface{}) []*regexp.Regexp {
+	out := make([]*regexp.Regexp, 0, len(in))
+	for _, v := range in {
+		out = append(out, regexp.MustCompile(v.(string)))
+	}
+	return out
+}
+
+func matchesAny(s string, patterns []*regexp.Regexp) bool {
+	for _, re := range patterns {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// waitForDeletion watches the named object until it is gone, or ctx is done.
+func waitForDeletion(ctx context.Context, ri dynamic.ResourceInterface, name string) error {
+	for {
+		live, err := ri.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+
+		w, err := ri.Watch(ctx, metav1.ListOptions{
+			FieldSelector:   fields.OneTermEqualSelector("metadata.name", name).String(),
+			ResourceVersion: live.GetResourceVersion(),
+		})
+		if err != nil {
+			return err
+		}
+		deleted, err := waitForDeletedEvent(ctx, w, name)
+		w.Stop()
+		if deleted || err != nil {
+			return err
+		}
+		// The watch was closed or expired, start over from the current version.
+	}
+}
+
+func waitForDeletedEvent(ctx context.Context, w watch.Interface, name string) (bool, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return false, nil
+			}
+			switch event.Type {
+			case watch.Deleted:
+				if obj, ok := event.Object.(metav1.Object); ok && obj.GetName() == name {
+					return true, nil
+				}
+			case watch.Error:
+				return false, nil
+			}
+		}
+	}
+}
+
+// updateServiceAnnotation sets the annotation key of a Service to the value computed by f
+// from its current value, or removes it when f returns an empty value. The Service is read
+// again and f called again when it was modified concurrently. Other annotations are left
+// untouched.
+func updateServiceAnnotation(ctx context.Context, conn kubernetes.Interface, namespace, name, key string, f func(value string) (string, error)) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		svc, err := conn.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		current := svc.Annotations[key]
+		value, err := f(current)
+		if err != nil {
+			return err
+		}
+		if value == current {
+			return nil
+		}
+
+		if value == "" {
+			delete(svc.Annotations, key)
+		} else {
+			if svc.Annotations == nil {
+				svc.Annotations = make(map[string]string)
+			}
+			svc.Annotations[key] = value
+		}
+		_, err = conn.CoreV1().Services(namespace).Update(ctx, svc, metav1.UpdateOptions{})
+		return err
+	})
+}
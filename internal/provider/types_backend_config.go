@@ -0,0 +1,191 @@
+package provider
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// The types below mirror the BackendConfig CRD served by the GKE ingress controller.
+// More info: https://github.com/kubernetes/ingress-gce/blob/master/pkg/apis/backendconfig/v1/types.go
+
+var backendConfigGVR = schema.GroupVersionResource{
+	Group:    "cloud.google.com",
+	Version:  "v1",
+	Resource: "backendconfigs",
+}
+
+// backendConfigV1beta1GVR is the older version of the CRD, still served by the GKE ingress
+// controller and accepted when importing.
+var backendConfigV1beta1GVR = schema.GroupVersionResource{
+	Group:    "cloud.google.com",
+	Version:  "v1beta1",
+	Resource: "backendconfigs",
+}
+
+const backendConfigKind = "BackendConfig"
+
+// Enum values are stored by GKE in upper case, but accepted in any case.
+var (
+	healthCheckTypes     = []string{"HTTP", "HTTPS", "HTTP2"}
+	sessionAffinityTypes = []string{"CLIENT_IP", "GENERATED_COOKIE", "NONE"}
+)
+
+type backendConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec backendConfigSpec `json:"spec"`
+}
+
+type backendConfigSpec struct {
+	Iap                   *iapConfig                   `json:"iap,omitempty"`
+	Cdn                   *cdnConfig                   `json:"cdn,omitempty"`
+	SecurityPolicy        *securityPolicyConfig        `json:"securityPolicy,omitempty"`
+	TimeoutSec            *int64                       `json:"timeoutSec,omitempty"`
+	ConnectionDraining    *connectionDrainingConfig    `json:"connectionDraining,omitempty"`
+	SessionAffinity       *sessionAffinityConfig       `json:"sessionAffinity,omitempty"`
+	CustomRequestHeaders  *customRequestHeadersConfig  `json:"customRequestHeaders,omitempty"`
+	CustomResponseHeaders *customResponseHeadersConfig `json:"customResponseHeaders,omitempty"`
+	HealthCheck           *healthCheckConfig           `json:"healthCheck,omitempty"`
+	Logging               *logConfig                   `json:"logging,omitempty"`
+}
+
+type iapConfig struct {
+	Enabled                bool                    `json:"enabled"`
+	OAuthClientCredentials *oauthClientCredentials `json:"oauthclientCredentials,omitempty"`
+}
+
+type oauthClientCredentials struct {
+	SecretName string `json:"secretName"`
+}
+
+type cdnConfig struct {
+	Enabled                 bool               `json:"enabled"`
+	CachePolicy             *cacheKeyPolicy    `json:"cachePolicy,omitempty"`
+	SignedURLCacheMaxAgeSec *int64             `json:"signedUrlCacheMaxAgeSec,omitempty"`
+	SignedURLKeys           []*signedURLKeyRef `json:"signedUrlKeys,omitempty"`
+}
+
+type cacheKeyPolicy struct {
+	IncludeHost          bool     `json:"includeHost,omitempty"`
+	IncludeProtocol      bool     `json:"includeProtocol,omitempty"`
+	IncludeQueryString   bool     `json:"includeQueryString,omitempty"`
+	QueryStringBlacklist []string `json:"queryStringBlacklist,omitempty"`
+	QueryStringWhitelist []string `json:"queryStringWhitelist,omitempty"`
+}
+
+type signedURLKeyRef struct {
+	KeyName    string `json:"keyName,omitempty"`
+	SecretName string `json:"secretName,omitempty"`
+}
+
+type securityPolicyConfig struct {
+	Name string `json:"name"`
+}
+
+type connectionDrainingConfig struct {
+	DrainingTimeoutSec int64 `json:"drainingTimeoutSec,omitempty"`
+}
+
+type sessionAffinityConfig struct {
+	AffinityType         string `json:"affinityType,omitempty"`
+	AffinityCookieTtlSec *int64 `json:"affinityCookieTtlSec,omitempty"`
+}
+
+type customRequestHeadersConfig struct {
+	Headers []string `json:"headers,omitempty"`
+}
+
+type customResponseHeadersConfig struct {
+	Headers []string `json:"headers,omitempty"`
+}
+
+type healthCheckConfig struct {
+	CheckIntervalSec   *int64  `json:"checkIntervalSec,omitempty"`
+	TimeoutSec         *int64  `json:"timeoutSec,omitempty"`
+	HealthyThreshold   *int64  `json:"healthyThreshold,omitempty"`
+	UnhealthyThreshold *int64  `json:"unhealthyThreshold,omitempty"`
+	Type               *string `json:"type,omitempty"`
+	Port               *int64  `json:"port,omitempty"`
+	RequestPath        *string `json:"requestPath,omitempty"`
+}
+
+type logConfig struct {
+	Enable     bool     `json:"enable,omitempty"`
+	SampleRate *float64 `json:"sampleRate,omitempty"`
+}
+
+func backendConfigToUnstructured(in *backendConfig) (*unstructured.Unstructured, error) {
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(in)
+	if err != nil {
+		return nil, err
+	}
+	return &unstructured.Unstructured{Object: obj}, nil
+}
+
+func backendConfigFromUnstructured(in *unstructured.Unstructured) (*backendConfig, error) {
+	out := &backendConfig{}
+	err := runtime.DefaultUnstructuredConverter.FromUnstructured(in.UnstructuredContent(), out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// unmodeledFields returns the paths of the fields in an unstructured value that have no
+// counterpart in the Go type t, and are therefore dropped when it is decoded into t.
+func unmodeledFields(in interface{}, t reflect.Type, path string) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var out []string
+	switch v := in.(type) {
+	case map[string]interface{}:
+		if t.Kind() != reflect.Struct {
+			return nil
+		}
+		fields := jsonFields(t)
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			f, ok := fields[k]
+			if !ok {
+				out = append(out, path+"."+k)
+				continue
+			}
+			out = append(out, unmodeledFields(v[k], f, path+"."+k)...)
+		}
+	case []interface{}:
+		if t.Kind() != reflect.Slice {
+			return nil
+		}
+		for i, e := range v {
+			out = append(out, unmodeledFields(e, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+	return out
+}
+
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
@@ -3,6 +3,8 @@ package provider
 import (
 	"encoding/base64"
 	"fmt"
+	"path"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -12,6 +14,68 @@ import (
 	utilValidation "k8s.io/apimachinery/pkg/util/validation"
 )
 
+// customHeaderVariables are the variables Google Cloud substitutes in custom header values.
+// More info: https://cloud.google.com/load-balancing/docs/https/custom-headers#variables
+var customHeaderVariables = []string{
+	"client_rtt_msec",
+	"client_region",
+	"client_region_subdivision",
+	"client_city",
+	"client_city_lat_long",
+	"client_encrypted",
+	"client_protocol",
+	"origin_request_header",
+	"server_ip",
+	"server_port",
+	"tls_sni_hostname",
+	"tls_version",
+	"tls_cipher_suite",
+	"tls_ja3_fingerprint",
+	"device_request_type",
+	"user_agent_family",
+	"client_cert_present",
+	"client_cert_chain_verified",
+	"client_cert_error",
+	"client_cert_sha256_fingerprint",
+	"client_cert_serial_number",
+	"client_cert_spiffe_id",
+	"client_cert_uri_sans",
+	"client_cert_dnsname_sans",
+	"client_cert_valid_not_before",
+	"client_cert_valid_not_after",
+}
+
+// customResponseHeaderVariables adds the Cloud CDN variables, which are only known once a response exists.
+var customResponseHeaderVariables = append([]string{
+	"cdn_cache_id",
+	"cdn_cache_status",
+}, customHeaderVariables...)
+
+// Google Cloud limits on the custom headers of a single backend service.
+// More info: https://cloud.google.com/load-balancing/docs/https/custom-headers
+const (
+	customHeadersMaxCount = 16
+	customHeadersMaxSize  = 8192
+)
+
+// hopByHopHeaders are connection-specific headers (RFC 7230 section 6.1) that a proxy
+// must not forward, so they cannot be set as custom headers.
+var hopByHopHeaders = []string{
+	"connection",
+	"keep-alive",
+	"proxy-authenticate",
+	"proxy-authorization",
+	"te",
+	"trailer",
+	"transfer-encoding",
+	"upgrade",
+}
+
+var (
+	headerNameRegexp     = regexp.MustCompile("^[!#$%&'*+\\-.^_`|~0-9A-Za-z]+$")
+	headerVariableRegexp = regexp.MustCompile(`\{([^{}]*)\}`)
+)
+
 func validateAnnotations(value interface{}, key string) (ws []string, es []error) {
 	m := value.(map[string]interface{})
 	for k := range m {
@@ -40,6 +104,55 @@ func validateBase64Encoded(v interface{}, key string) (ws []string, es []error)
 	return
 }
 
+// validateBase64URLEncoded checks for the URL-safe alphabet (RFC 4648 section 5) used by
+// Cloud CDN signed URL keys, which validateBase64Encoded would reject because of '-' and '_'.
+func validateBase64URLEncoded(v interface{}, key string) (ws []string, es []error) {
+	s, ok := v.(string)
+	if !ok {
+		es = []error{fmt.Errorf("%s: must be a non-nil base64url-encoded string", key)}
+		return
+	}
+
+	_, err := base64.URLEncoding.DecodeString(s)
+	if err != nil {
+		es = []error{fmt.Errorf("%s: must be a base64url-encoded string", key)}
+		return
+	}
+	return
+}
+
+func validateSignedURLKeyName(value interface{}, key string) (ws []string, es []error) {
+	v := value.(string)
+	errors := utilValidation.IsDNS1035Label(v)
+	if len(errors) > 0 {
+		for _, err := range errors {
+			es = append(es, fmt.Errorf("%s %s", key, err))
+		}
+	}
+	return
+}
+
+// validateNEGName checks the name of a network endpoint group, a Google Cloud resource name.
+func validateNEGName(value interface{}, key string) (ws []string, es []error) {
+	for _, err := range utilValidation.IsDNS1035Label(value.(string)) {
+		es = append(es, fmt.Errorf("%s %s", key, err))
+	}
+	return
+}
+
+func validateSignedURLKeyValue(value interface{}, key string) (ws []string, es []error) {
+	ws, es = validateBase64URLEncoded(value, key)
+	if len(es) > 0 {
+		return
+	}
+
+	b, _ := base64.URLEncoding.DecodeString(value.(string))
+	if len(b) != signedURLKeySize {
+		es = append(es, fmt.Errorf("%s: must encode a %d-bit key, got %d bits", key, signedURLKeySize*8, len(b)*8))
+	}
+	return
+}
+
 func validateBase64EncodedMap(value interface{}, key string) (ws []string, es []error) {
 	m, ok := value.(map[string]interface{})
 	if !ok {
@@ -68,6 +181,21 @@ func validateName(value interface{}, key string) (ws []string, es []error) {
 	return
 }
 
+func validateNamespaceName(value interface{}, key string) (ws []string, es []error) {
+	v := value.(string)
+	for _, err := range apiValidation.ValidateNamespaceName(v, false) {
+		es = append(es, fmt.Errorf("%s %s", key, err))
+	}
+	return
+}
+
+func validateGlob(value interface{}, key string) (ws []string, es []error) {
+	if _, err := path.Match(value.(string), ""); err != nil {
+		es = append(es, fmt.Errorf("%s is not a valid glob pattern: %s", key, err))
+	}
+	return
+}
+
 func validateGenerateName(value interface{}, key string) (ws []string, es []error) {
 	v := value.(string)
 
@@ -98,6 +226,27 @@ func validateLabels(value interface{}, key string) (ws []string, es []error) {
 	return
 }
 
+// validateAppProtocols checks a map of Service port names to app protocols.
+func validateAppProtocols(value interface{}, key string) (ws []string, es []error) {
+	for port, v := range value.(map[string]interface{}) {
+		_, errs := validatePortName(port, fmt.Sprintf("%s (%q)", key, port))
+		es = append(es, errs...)
+		_, errs = validateAttributeValueIsIn(appProtocols)(v, key+"."+port)
+		es = append(es, errs...)
+	}
+	return
+}
+
+// validateLabelSelector checks labels used to select objects, refusing an empty selector
+// which would match them all.
+func validateLabelSelector(value interface{}, key string) (ws []string, es []error) {
+	if len(value.(map[string]interface{})) == 0 {
+		es = append(es, fmt.Errorf("%s must have at least one label", key))
+		return
+	}
+	return validateLabels(value, key)
+}
+
 func validatePortNum(value interface{}, key string) (ws []string, es []error) {
 	errors := utilValidation.IsValidPortNum(value.(int))
 	if len(errors) > 0 {
@@ -134,6 +283,23 @@ func validatePortNumOrName(value interface{}, key string) (ws []string, es []err
 	}
 }
 
+// validateBackendConfigPorts checks a map of Service port names or numbers to backendconfig
+// names.
+func validateBackendConfigPorts(value interface{}, key string) (ws []string, es []error) {
+	for port, v := range value.(map[string]interface{}) {
+		_, errs := validatePortNumOrName(port, fmt.Sprintf("%s (%q)", key, port))
+		es = append(es, errs...)
+		name, ok := v.(string)
+		if !ok {
+			es = append(es, fmt.Errorf("%s.%s (%#v): Expected value to be string", key, port, v))
+			continue
+		}
+		_, errs = validateName(name, key+"."+port)
+		es = append(es, errs...)
+	}
+	return
+}
+
 func validateResourceList(value interface{}, key string) (ws []string, es []error) {
 	m := value.(map[string]interface{})
 	for k, value := range m {
@@ -219,6 +385,43 @@ func validateTypeStringNullableInt(v interface{}, k string) (ws []string, es []e
 	return
 }
 
+// validateTypeStringNullableNonNegativeInt is validateTypeStringNullableInt for arguments
+// that must also not be negative.
+func validateTypeStringNullableNonNegativeInt(v interface{}, k string) (ws []string, es []error) {
+	ws, es = validateTypeStringNullableInt(v, k)
+	if len(es) > 0 {
+		return
+	}
+
+	if value := v.(string); value != "" {
+		if i, _ := strconv.ParseInt(value, 10, 64); i < 0 {
+			es = append(es, fmt.Errorf("%s must be greater than or equal to 0", k))
+		}
+	}
+
+	return
+}
+
+// validateTypeStringNullableFloat provides custom error messaging for TypeString floats
+// Some arguments require a float value or unspecified, empty field.
+func validateTypeStringNullableFloat(v interface{}, k string) (ws []string, es []error) {
+	value, ok := v.(string)
+	if !ok {
+		es = append(es, fmt.Errorf("expected type of %s to be string", k))
+		return
+	}
+
+	if value == "" {
+		return
+	}
+
+	if _, err := strconv.ParseFloat(value, 64); err != nil {
+		es = append(es, fmt.Errorf("%s: cannot parse '%s' as float: %s", k, value, err))
+	}
+
+	return
+}
+
 func validateModeBits(value interface{}, key string) (ws []string, es []error) {
 	if !strings.HasPrefix(value.(string), "0") {
 		es = append(es, fmt.Errorf("%s: value %s should start with '0' (octal numeral)", key, value.(string)))
@@ -245,6 +448,13 @@ func validateAttributeValueDoesNotContain(searchString string) schema.SchemaVali
 	}
 }
 
+func validateRegexp(value interface{}, key string) (ws []string, es []error) {
+	if _, err := regexp.Compile(value.(string)); err != nil {
+		es = append(es, fmt.Errorf("%s is not a valid regular expression: %s", key, err))
+	}
+	return
+}
+
 func validateAttributeValueIsIn(validValues []string) schema.SchemaValidateFunc {
 	return func(v interface{}, k string) (ws []string, errors []error) {
 		input := v.(string)
@@ -265,6 +475,30 @@ func validateAttributeValueIsIn(validValues []string) schema.SchemaValidateFunc
 	}
 }
 
+// validateCaseInsensitiveEnum accepts the valid values in any case, and warns when the
+// value will be rewritten to the canonical case stored by the API.
+func validateCaseInsensitiveEnum(validValues []string) schema.SchemaValidateFunc {
+	return func(v interface{}, k string) (ws []string, errors []error) {
+		input := v.(string)
+		for _, s := range validValues {
+			if s == input {
+				return
+			}
+		}
+
+		canonical := canonicalEnumValue(input, validValues)
+		if canonical == input {
+			errors = append(errors, fmt.Errorf(
+				"%q must contain a value from %#v, got %q",
+				k, validValues, input))
+			return
+		}
+
+		ws = append(ws, fmt.Sprintf("%q: %q is stored as %q, use the canonical value to avoid this warning", k, input, canonical))
+		return
+	}
+}
+
 func validateTypeStringNullableIntOrPercent(v interface{}, key string) (ws []string, es []error) {
 	value, ok := v.(string)
 	if !ok {
@@ -290,3 +524,84 @@ func validateTypeStringNullableIntOrPercent(v interface{}, key string) (ws []str
 
 	return
 }
+
+// validateHTTPHeaderName checks that the name is an RFC 7230 token and not a hop-by-hop header.
+func validateHTTPHeaderName(value interface{}, key string) (ws []string, es []error) {
+	v := value.(string)
+	if !headerNameRegexp.MatchString(v) {
+		es = append(es, fmt.Errorf("%s (%q) must be a valid HTTP header name (RFC 7230 token)", key, v))
+		return
+	}
+	for _, h := range hopByHopHeaders {
+		if strings.EqualFold(v, h) {
+			es = append(es, fmt.Errorf("%s (%q) is a hop-by-hop header and cannot be set", key, v))
+			return
+		}
+	}
+	return
+}
+
+// validateCustomHeaderValue checks that every {variable} in the value is one of the given variables.
+func validateCustomHeaderValue(variables []string) schema.SchemaValidateFunc {
+	return func(value interface{}, key string) (ws []string, es []error) {
+		v := value.(string)
+		for _, m := range headerVariableRegexp.FindAllStringSubmatch(v, -1) {
+			isValid := false
+			for _, s := range variables {
+				if s == m[1] {
+					isValid = true
+					break
+				}
+			}
+			if !isValid {
+				es = append(es, fmt.Errorf("%s (%q) uses unsupported variable %q", key, v, m[0]))
+			}
+		}
+		return
+	}
+}
+
+// validateCustomHeader validates a custom header in the "Name:value" form used by BackendConfig.
+func validateCustomHeader(variables []string) schema.SchemaValidateFunc {
+	return func(value interface{}, key string) (ws []string, es []error) {
+		v := value.(string)
+		parts := strings.SplitN(v, ":", 2)
+		if len(parts) != 2 {
+			es = append(es, fmt.Errorf("%s (%q) must be in the form \"Name:value\"", key, v))
+			return
+		}
+
+		_, errs := validateHTTPHeaderName(parts[0], key)
+		es = append(es, errs...)
+		_, errs = validateCustomHeaderValue(variables)(parts[1], key)
+		es = append(es, errs...)
+		return
+	}
+}
+
+// validateCustomHeaderLimits checks the constraints that span all headers of a backend:
+// the number of headers, their combined size and duplicate names.
+func validateCustomHeaderLimits(headers []string, key string) error {
+	if len(headers) > customHeadersMaxCount {
+		return fmt.Errorf("%s: at most %d headers are allowed per backend, got %d", key, customHeadersMaxCount, len(headers))
+	}
+
+	size := 0
+	seen := make(map[string]bool)
+	for _, h := range headers {
+		size += len(h)
+		name := strings.ToLower(strings.SplitN(h, ":", 2)[0])
+		if name == "" {
+			// Unknown until apply.
+			continue
+		}
+		if seen[name] {
+			return fmt.Errorf("%s: header %q is set more than once", key, strings.SplitN(h, ":", 2)[0])
+		}
+		seen[name] = true
+	}
+	if size > customHeadersMaxSize {
+		return fmt.Errorf("%s: headers must not exceed %d bytes in total, got %d", key, customHeadersMaxSize, size)
+	}
+	return nil
+}
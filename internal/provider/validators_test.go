@@ -0,0 +1,263 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestValidateHTTPHeaderName(t *testing.T) {
+	cases := map[string]bool{
+		"X-Client-Region":          true,
+		"x-custom_header.v1":       true,
+		"X!#$%&'*+-.^_`|~Header":   true,
+		"Cache-Control":            true,
+		"":                         false,
+		"X Client":                 false,
+		"X-Client:Region":          false,
+		"X-Client(Region)":         false,
+		"X-Client\"Region\"":       false,
+		"X-Clíent":                 false,
+		"Connection":               false,
+		"keep-alive":               false,
+		"Proxy-Authenticate":       false,
+		"PROXY-AUTHORIZATION":      false,
+		"TE":                       false,
+		"Trailer":                  false,
+		"Transfer-Encoding":        false,
+		"upgrade":                  false,
+		"X-Connection":             true,
+		"Connection-Timeout-Hints": true,
+	}
+
+	for name, valid := range cases {
+		_, es := validateHTTPHeaderName(name, "name")
+		if valid && len(es) > 0 {
+			t.Errorf("%q: unexpected errors: %v", name, es)
+		}
+		if !valid && len(es) == 0 {
+			t.Errorf("%q: expected an error", name)
+		}
+	}
+}
+
+func TestValidateCustomHeaderValue(t *testing.T) {
+	cases := []struct {
+		value     string
+		variables []string
+		valid     bool
+	}{
+		{"static", customHeaderVariables, true},
+		{"", customHeaderVariables, true},
+		{"{client_region}", customHeaderVariables, true},
+		{"{client_city}, {client_region_subdivision}", customHeaderVariables, true},
+		{"{client_rtt_msec}ms", customHeaderVariables, true},
+		{"{client_country}", customHeaderVariables, false},
+		{"{}", customHeaderVariables, false},
+		{"{Client_Region}", customHeaderVariables, false},
+		{"{cdn_cache_status}", customHeaderVariables, false},
+		{"{cdn_cache_status}", customResponseHeaderVariables, true},
+		{"{tls_version}", customResponseHeaderVariables, true},
+	}
+
+	for _, tc := range cases {
+		_, es := validateCustomHeaderValue(tc.variables)(tc.value, "value")
+		if tc.valid && len(es) > 0 {
+			t.Errorf("%q: unexpected errors: %v", tc.value, es)
+		}
+		if !tc.valid && len(es) == 0 {
+			t.Errorf("%q: expected an error", tc.value)
+		}
+	}
+}
+
+func TestValidateCustomHeader(t *testing.T) {
+	cases := map[string]bool{
+		"X-Client-Region:{client_region}":      true,
+		"X-Client-Region: {client_region}":     true,
+		"X-Empty:":                             true,
+		"X-Time:12:30":                         true,
+		"X-Client-Region":                      false,
+		":{client_region}":                     false,
+		"X Client:value":                       false,
+		"Connection:close":                     false,
+		"Transfer-Encoding:chunked":            false,
+		"X-Client-Country:{client_country}":    false,
+		"X-Cache:{cdn_cache_status}":           false,
+		"X-Cache-Id:{client_region}{cdn_cach}": false,
+	}
+
+	for header, valid := range cases {
+		_, es := validateCustomHeader(customHeaderVariables)(header, "headers")
+		if valid && len(es) > 0 {
+			t.Errorf("%q: unexpected errors: %v", header, es)
+		}
+		if !valid && len(es) == 0 {
+			t.Errorf("%q: expected an error", header)
+		}
+	}
+
+	if _, es := validateCustomHeader(customResponseHeaderVariables)("X-Cache:{cdn_cache_status}", "headers"); len(es) > 0 {
+		t.Errorf("response header: unexpected errors: %v", es)
+	}
+}
+
+func TestValidateCustomHeaderLimits(t *testing.T) {
+	headers := func(n, size int) []string {
+		l := make([]string, 0, n)
+		for i := 0; i < n; i++ {
+			h := fmt.Sprintf("X-Header-%02d:", i)
+			l = append(l, h+strings.Repeat("v", size-len(h)))
+		}
+		return l
+	}
+
+	cases := map[string]struct {
+		headers     []string
+		errContains string
+	}{
+		"none":                 {},
+		"16 headers":           {headers: headers(16, 20)},
+		"17 headers":           {headers: headers(17, 20), errContains: "at most 16 headers are allowed per backend, got 17"},
+		"8192 bytes":           {headers: headers(16, 512)},
+		"8193 bytes":           {headers: append(headers(15, 512), "X-Last:"+strings.Repeat("v", 513-len("X-Last:"))), errContains: "must not exceed 8192 bytes in total, got 8193"},
+		"one large header":     {headers: []string{"X-Large:" + strings.Repeat("v", 8193-len("X-Large:"))}, errContains: "got 8193"},
+		"distinct names":       {headers: []string{"X-Region:{client_region}", "X-Region-City:{client_city}"}},
+		"duplicate":            {headers: []string{"X-Region:a", "X-Region:b"}, errContains: `header "X-Region" is set more than once`},
+		"duplicate in case":    {headers: []string{"X-Region:a", "x-region:b"}, errContains: `header "x-region" is set more than once`},
+		"unknown until apply":  {headers: []string{":a", ":b"}},
+		"duplicate with value": {headers: []string{"X-Region:{client_region}", "X-REGION:{client_region}"}, errContains: "set more than once"},
+	}
+
+	for name, tc := range cases {
+		err := validateCustomHeaderLimits(tc.headers, "spec.0.custom_request_headers")
+		if tc.errContains == "" && err != nil {
+			t.Errorf("%s: unexpected error: %s", name, err)
+		}
+		if tc.errContains != "" && (err == nil || !strings.Contains(err.Error(), tc.errContains)) {
+			t.Errorf("%s: expected an error containing %q, got %v", name, tc.errContains, err)
+		}
+	}
+}
+
+func TestCustomRequestHeadersForms(t *testing.T) {
+	header := func(name, value string) interface{} {
+		return map[string]interface{}{"name": name, "value": value}
+	}
+	cases := map[string]struct {
+		headers     map[string]interface{}
+		errContains string
+	}{
+		"headers": {
+			headers: map[string]interface{}{"headers": []interface{}{"X-Region:{client_region}"}},
+		},
+		"header blocks": {
+			headers: map[string]interface{}{"header": []interface{}{header("X-Region", "{client_region}")}},
+		},
+		"both forms": {
+			headers: map[string]interface{}{
+				"headers": []interface{}{"X-Region:{client_region}"},
+				"header":  []interface{}{header("X-City", "{client_city}")},
+			},
+			errContains: "only one of",
+		},
+		"neither form": {
+			headers:     map[string]interface{}{},
+			errContains: "one of",
+		},
+		"duplicate header blocks": {
+			headers: map[string]interface{}{"header": []interface{}{
+				header("X-Region", "{client_region}"),
+				header("x-REGION", "{client_region_subdivision}"),
+			}},
+			errContains: "set more than once",
+		},
+	}
+
+	for name, tc := range cases {
+		config := terraform.NewResourceConfigRaw(map[string]interface{}{
+			"metadata": []interface{}{map[string]interface{}{"name": "web", "namespace": "default"}},
+			"spec": []interface{}{map[string]interface{}{
+				"custom_request_headers": []interface{}{tc.headers},
+			}},
+		})
+		r := resourceBackendConfig()
+		var err error
+		if diags := r.Validate(config); diags.HasError() {
+			err = fmt.Errorf("%s: %s", diags[0].Summary, diags[0].Detail)
+		} else {
+			_, err = r.Diff(context.Background(), nil, config, &apiClient{namespace: "default"})
+		}
+		if tc.errContains == "" && err != nil {
+			t.Errorf("%s: unexpected error: %s", name, err)
+		}
+		if tc.errContains != "" && (err == nil || !strings.Contains(err.Error(), tc.errContains)) {
+			t.Errorf("%s: expected an error containing %q, got %v", name, tc.errContains, err)
+		}
+	}
+}
+
+func TestValidateCaseInsensitiveEnum(t *testing.T) {
+	cases := []struct {
+		value string
+		warns bool
+		valid bool
+	}{
+		{"HTTP", false, true},
+		{"HTTP2", false, true},
+		{"http", true, true},
+		{"Https", true, true},
+		{"GRPC", false, false},
+		{"", false, false},
+	}
+
+	for _, tc := range cases {
+		ws, es := validateCaseInsensitiveEnum(healthCheckTypes)(tc.value, "type")
+		if (len(es) == 0) != tc.valid {
+			t.Errorf("%q: expected valid %t, got errors %v", tc.value, tc.valid, es)
+		}
+		if (len(ws) > 0) != tc.warns {
+			t.Errorf("%q: expected warning %t, got %v", tc.value, tc.warns, ws)
+		}
+	}
+}
+
+func TestCanonicalEnumValue(t *testing.T) {
+	cases := map[string]string{
+		"HTTP":             "HTTP",
+		"http":             "HTTP",
+		"hTTp2":            "HTTP2",
+		"generated_cookie": "GENERATED_COOKIE",
+		"grpc":             "grpc",
+	}
+
+	for v, expected := range cases {
+		if actual := canonicalEnumValue(v, append(healthCheckTypes, sessionAffinityTypes...)); actual != expected {
+			t.Errorf("%q: expected %q, got %q", v, expected, actual)
+		}
+	}
+}
+
+func TestValidateTypeStringNullableNonNegativeInt(t *testing.T) {
+	cases := map[string]bool{
+		"":     true,
+		"0":    true,
+		"3600": true,
+		"-1":   false,
+		"1.5":  false,
+		"ten":  false,
+	}
+
+	for v, valid := range cases {
+		_, es := validateTypeStringNullableNonNegativeInt(v, "affinity_cookie_ttl_sec")
+		if valid && len(es) > 0 {
+			t.Errorf("%q: unexpected errors: %v", v, es)
+		}
+		if !valid && len(es) == 0 {
+			t.Errorf("%q: expected an error", v)
+		}
+	}
+}